@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNoCredentials is returned when the request does not carry a bearer token
+	ErrNoCredentials = errors.New("missing bearer token")
+	// ErrInvalidCredentials is returned when no authenticator accepts the bearer token
+	ErrInvalidCredentials = errors.New("invalid bearer token")
+)
+
+// Authenticator verifies a bearer token and returns the user it belongs to.
+// The user is namespaced by the authenticator, so that the users of different authenticators never collide,
+// e.g. oidc:<issuer>#<subject> or apikey:<name>.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (string, error)
+}
+
+type userContextKey struct{}
+
+// UserFromContext returns the namespaced authenticated user, it is empty if authentication is disabled
+func UserFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(userContextKey{}).(string)
+	return user
+}
+
+// WithUser returns a copy of the context which holds the user
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+// Middleware authenticates every request with the bearer token of the Authorization header.
+// The first authenticator which accepts the token determines the user, requests which are
+// not accepted by any authenticator are answered with 401.
+// A GET request without Authorization header may carry a token of tokens in the StreamTokenParam query parameter instead.
+func Middleware(tokens *StreamTokens, authenticators ...Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := authenticate(r, tokens, authenticators)
+			if err != nil {
+				log.Printf("%s %s unauthorized: %v", r.Method, r.RequestURI, err)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="backend"`)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), user)))
+		})
+	}
+}
+
+func authenticate(r *http.Request, tokens *StreamTokens, authenticators []Authenticator) (string, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		// the EventSource of a browser can not set headers, it passes a stream token in the query
+		streamToken := r.URL.Query().Get(StreamTokenParam)
+		if tokens == nil || streamToken == "" || r.Method != http.MethodGet {
+			return "", ErrNoCredentials
+		}
+		user, err := tokens.Authenticate(r.Context(), streamToken)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+		}
+		return user, nil
+	}
+
+	var errs []string
+	for _, authenticator := range authenticators {
+		user, err := authenticator.Authenticate(r.Context(), token)
+		if err == nil {
+			return user, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return "", fmt.Errorf("%w: %s", ErrInvalidCredentials, strings.Join(errs, "; "))
+}
+
+// bearerToken returns the token of the Authorization header
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
+
+// APIKeyAuthenticator accepts static API keys
+type APIKeyAuthenticator struct {
+	users map[string]string // users maps the API keys to their user
+}
+
+// NewAPIKeyAuthenticator parses comma separated user:key pairs, e.g. "alice:key1,bob:key2"
+func NewAPIKeyAuthenticator(keys string) (*APIKeyAuthenticator, error) {
+	users := make(map[string]string)
+	for _, pair := range strings.Split(keys, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		user, key, ok := strings.Cut(pair, ":")
+		if !ok || user == "" || key == "" {
+			return nil, fmt.Errorf("invalid API key, expected user:key")
+		}
+		users[key] = user
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("no API keys configured")
+	}
+	return &APIKeyAuthenticator{users: users}, nil
+}
+
+// Authenticate compares the token with every API key in constant time, the user is apikey:<name>
+func (a *APIKeyAuthenticator) Authenticate(_ context.Context, token string) (string, error) {
+	found := ""
+	for key, user := range a.users {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1 {
+			found = user
+		}
+	}
+	if found == "" {
+		return "", fmt.Errorf("unknown API key")
+	}
+	return "apikey:" + found, nil
+}
@@ -0,0 +1,274 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testIssuer is a local stand-in for an OIDC issuer which signs tokens with its RSA and EC keys
+type testIssuer struct {
+	server    *httptest.Server
+	rsaKey    *rsa.PrivateKey
+	ecKey     *ecdsa.PrivateKey
+	jwksCalls int32
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{rsaKey: rsaKey, ecKey: ecKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   issuer.server.URL,
+			"jwks_uri": issuer.server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&issuer.jwksCalls, 1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA", "kid": "rsa", "use": "sig",
+					"n": encode(rsaKey.N.Bytes()),
+					"e": encode(big.NewInt(int64(rsaKey.E)).Bytes()),
+				},
+				{
+					"kty": "EC", "kid": "ec", "crv": "P-256",
+					"x": encode(ecKey.X.FillBytes(make([]byte, 32))),
+					"y": encode(ecKey.Y.FillBytes(make([]byte, 32))),
+				},
+				// keys of an unsupported type or curve are skipped
+				{"kty": "OKP", "kid": "x448", "crv": "X448", "x": encode(make([]byte, 56))},
+				{"kty": "EC", "kid": "k1", "crv": "secp256k1", "x": encode(make([]byte, 32)), "y": encode(make([]byte, 32))},
+			},
+		})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+// token returns a JWT signed with the key of the given key id
+func (i *testIssuer) token(t *testing.T, kid string, claims map[string]interface{}) string {
+	alg := "RS256"
+	if kid == "ec" {
+		alg = "ES256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := encode(header) + "." + encode(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	if kid == "ec" {
+		r, s, err := ecdsa.Sign(rand.Reader, i.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	} else {
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, i.rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signed + "." + encode(signature)
+}
+
+func (i *testIssuer) claims(user string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss": i.server.URL,
+		"aud": []string{"backend"},
+		"sub": user,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestOIDCAuthenticator(t *testing.T) {
+	issuer := newTestIssuer(t)
+	authenticator, err := NewOIDCAuthenticator(context.Background(), issuer.server.URL, "backend", "")
+	if err != nil {
+		t.Fatalf("failed to discover the issuer: %v", err)
+	}
+
+	expired := issuer.claims("alice")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	otherAudience := issuer.claims("alice")
+	otherAudience["aud"] = "other"
+	otherIssuer := issuer.claims("alice")
+	otherIssuer["iss"] = "https://evil.example.com"
+	tampered := issuer.token(t, "rsa", issuer.claims("alice"))
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+
+	tests := []struct {
+		name     string
+		token    string
+		wantUser string
+	}{
+		{name: "RS256 token", token: issuer.token(t, "rsa", issuer.claims("alice")), wantUser: "oidc:" + issuer.server.URL + "#alice"},
+		{name: "ES256 token", token: issuer.token(t, "ec", issuer.claims("bob")), wantUser: "oidc:" + issuer.server.URL + "#bob"},
+		{name: "expired token", token: issuer.token(t, "rsa", expired)},
+		{name: "other audience", token: issuer.token(t, "rsa", otherAudience)},
+		{name: "other issuer", token: issuer.token(t, "rsa", otherIssuer)},
+		{name: "unknown key", token: issuer.token(t, "unknown", issuer.claims("alice"))},
+		{name: "tampered signature", token: tampered},
+		{name: "not a JWT", token: "api-key"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			user, err := authenticator.Authenticate(context.Background(), tc.token)
+			if tc.wantUser == "" {
+				if err == nil {
+					t.Fatalf("expected the token to be rejected, got user %s", user)
+				}
+				return
+			}
+			if err != nil || user != tc.wantUser {
+				t.Fatalf("expected user %s, got %s, %v", tc.wantUser, user, err)
+			}
+		})
+	}
+
+	if calls := atomic.LoadInt32(&issuer.jwksCalls); calls != 1 {
+		t.Fatalf("expected the JWKS to be fetched once, fetched %d times", calls)
+	}
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	if _, err := NewAPIKeyAuthenticator("alice"); err == nil {
+		t.Fatal("expected an error for a key without user")
+	}
+
+	authenticator, err := NewAPIKeyAuthenticator("alice:key-a, bob:key-b")
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+
+	if user, err := authenticator.Authenticate(context.Background(), "key-b"); err != nil || user != "apikey:bob" {
+		t.Fatalf("expected user apikey:bob, got %s, %v", user, err)
+	}
+	if _, err := authenticator.Authenticate(context.Background(), "key-c"); err == nil {
+		t.Fatal("expected an unknown key to be rejected")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	authenticator, err := NewAPIKeyAuthenticator("alice:key-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := NewStreamTokens(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamToken, _ := tokens.Issue("apikey:alice")
+	handler := Middleware(tokens, authenticator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(UserFromContext(r.Context())))
+	}))
+
+	tests := []struct {
+		method        string
+		target        string
+		authorization string
+		wantStatus    int
+		wantBody      string
+	}{
+		{authorization: "Bearer key-a", wantStatus: http.StatusOK, wantBody: "apikey:alice"},
+		{authorization: "bearer key-a", wantStatus: http.StatusOK, wantBody: "apikey:alice"},
+		{authorization: "Bearer key-b", wantStatus: http.StatusUnauthorized},
+		{authorization: "Basic a2V5LWE=", wantStatus: http.StatusUnauthorized},
+		{authorization: "", wantStatus: http.StatusUnauthorized},
+		// the stream token is accepted in the query of GET requests
+		{target: "/api/watch?access_token=" + streamToken, wantStatus: http.StatusOK, wantBody: "apikey:alice"},
+		{method: http.MethodPost, target: "/api/watch?access_token=" + streamToken, wantStatus: http.StatusUnauthorized},
+		{target: "/api/watch?access_token=" + streamToken + "x", wantStatus: http.StatusUnauthorized},
+		// an API key is not accepted in the query
+		{target: "/api/watch?access_token=key-a", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		if tc.method == "" {
+			tc.method = http.MethodGet
+		}
+		if tc.target == "" {
+			tc.target = "/api/subs"
+		}
+		req := httptest.NewRequest(tc.method, tc.target, nil)
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("%s %s %q: expected status %d, got %d", tc.method, tc.target, tc.authorization, tc.wantStatus, rec.Code)
+		}
+		if tc.wantBody != "" && rec.Body.String() != tc.wantBody {
+			t.Fatalf("%s %s %q: expected user %s, got %s", tc.method, tc.target, tc.authorization, tc.wantBody, rec.Body.String())
+		}
+	}
+
+	if _, err := authenticate(httptest.NewRequest(http.MethodGet, "/", nil), nil, nil); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("expected ErrNoCredentials, got: %v", err)
+	}
+}
+
+func TestStreamTokens(t *testing.T) {
+	tokens, err := NewStreamTokens(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tokens.now = func() time.Time { return now }
+
+	token, expires := tokens.Issue("oidc:https://issuer#alice")
+	if !expires.Equal(now.Add(time.Minute)) {
+		t.Fatalf("expected the token to expire after a minute, expires at %s", expires)
+	}
+	if user, err := tokens.Authenticate(context.Background(), token); err != nil || user != "oidc:https://issuer#alice" {
+		t.Fatalf("expected the user of the token, got %s, %v", user, err)
+	}
+
+	// a token of another backend instance is rejected
+	other, err := NewStreamTokens(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Authenticate(context.Background(), token); err == nil {
+		t.Fatal("expected a token signed with another key to be rejected")
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := tokens.Authenticate(context.Background(), token); err == nil {
+		t.Fatal("expected an expired token to be rejected")
+	}
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+// minRefreshInterval limits how often the JWKS is fetched for unknown key ids
+const minRefreshInterval = time.Minute
+
+// supportedSigningAlgs are the JWT algorithms the tokens may be signed with
+var supportedSigningAlgs = []string{
+	oidc.RS256, oidc.RS384, oidc.RS512,
+	oidc.ES256, oidc.ES384, oidc.ES512,
+	oidc.PS256, oidc.PS384, oidc.PS512,
+}
+
+// JWKSAuthenticator verifies JWTs signed by a key of a JSON Web Key Set
+type JWKSAuthenticator struct {
+	verifier      *oidc.IDTokenVerifier
+	usernameClaim string
+}
+
+// NewJWKSAuthenticator returns an authenticator for the tokens signed by the keys at jwksURL.
+// The issuer and audience are checked if they are not empty, the user is read from the usernameClaim which defaults to sub.
+func NewJWKSAuthenticator(jwksURL, issuer, audience, usernameClaim string) *JWKSAuthenticator {
+	if usernameClaim == "" {
+		usernameClaim = "sub"
+	}
+	keys := &keySet{
+		jwksURL: jwksURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		now:     time.Now,
+		keys:    make(map[string]jose.JSONWebKey),
+	}
+	return &JWKSAuthenticator{
+		verifier: oidc.NewVerifier(issuer, keys, &oidc.Config{
+			ClientID:             audience,
+			SkipClientIDCheck:    audience == "",
+			SkipIssuerCheck:      issuer == "",
+			SupportedSigningAlgs: supportedSigningAlgs,
+		}),
+		usernameClaim: usernameClaim,
+	}
+}
+
+// NewOIDCAuthenticator discovers the JWKS of the OIDC issuer and returns an authenticator for its tokens
+func NewOIDCAuthenticator(ctx context.Context, issuer, audience, usernameClaim string) (*JWKSAuthenticator, error) {
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC issuer %s: %w", issuer, err)
+	}
+
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := provider.Claims(&discovery); err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC issuer %s: %w", issuer, err)
+	}
+	if discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC issuer %s does not provide a jwks_uri", issuer)
+	}
+
+	return NewJWKSAuthenticator(discovery.JWKSURI, issuer, audience, usernameClaim), nil
+}
+
+// Authenticate verifies the signature and the claims of the JWT and returns the user as oidc:<iss>#<username claim>
+func (a *JWKSAuthenticator) Authenticate(ctx context.Context, token string) (string, error) {
+	idToken, err := a.verifier.Verify(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return "", fmt.Errorf("invalid JWT claims: %w", err)
+	}
+
+	user, _ := claims[a.usernameClaim].(string)
+	if user == "" {
+		return "", fmt.Errorf("JWT has no %s claim", a.usernameClaim)
+	}
+	return "oidc:" + idToken.Issuer + "#" + user, nil
+}
+
+// keySet verifies the JWT signatures with the keys of a JWKS. Unlike the remote key set of go-oidc,
+// it fetches the JWKS for unknown key ids at most once per minRefreshInterval.
+type keySet struct {
+	jwksURL string
+	client  *http.Client
+	now     func() time.Time
+
+	mu          sync.RWMutex
+	keys        map[string]jose.JSONWebKey
+	lastRefresh time.Time
+}
+
+// VerifySignature verifies the signature of the JWT and returns its payload, see oidc.KeySet
+func (s *keySet) VerifySignature(ctx context.Context, token string) ([]byte, error) {
+	jws, err := jose.ParseSigned(token)
+	if err != nil {
+		return nil, fmt.Errorf("token is not a JWT: %w", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, errors.New("JWT must have exactly one signature")
+	}
+
+	key, err := s.key(ctx, jws.Signatures[0].Header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := jws.Verify(&key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT signature: %w", err)
+	}
+	return payload, nil
+}
+
+// key returns the public key by id, the JWKS is refreshed for unknown key ids
+func (s *keySet) key(ctx context.Context, kid string) (jose.JSONWebKey, error) {
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	refreshAllowed := s.now().Sub(s.lastRefresh) >= minRefreshInterval
+	s.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if !refreshAllowed {
+		return jose.JSONWebKey{}, fmt.Errorf("unknown JWT key id %q", kid)
+	}
+
+	if err := s.refresh(ctx); err != nil {
+		return jose.JSONWebKey{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	return jose.JSONWebKey{}, fmt.Errorf("unknown JWT key id %q", kid)
+}
+
+// refresh fetches the JWKS and replaces the known keys.
+// Keys of an unsupported type or curve are skipped, so that they do not break the other keys.
+func (s *keySet) refresh(ctx context.Context) error {
+	var jwks struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	err := getJSON(ctx, s.client, s.jwksURL, &jwks)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastRefresh = s.now()
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	keys := make(map[string]jose.JSONWebKey)
+	for _, raw := range jwks.Keys {
+		var jwk jose.JSONWebKey
+		if err := jwk.UnmarshalJSON(raw); err != nil {
+			log.Printf("skipping JWK of %s: %v", s.jwksURL, err)
+			continue
+		}
+		if jwk.Use != "" && jwk.Use != "sig" || !jwk.IsPublic() {
+			continue
+		}
+		keys[jwk.KeyID] = jwk
+	}
+	s.keys = keys
+	return nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/vladislavpaskar/hackathon2022/components/backend/auth"
+)
+
+const (
+	// authOIDCIssuerEnv is the OIDC issuer whose bearer tokens are accepted
+	authOIDCIssuerEnv = "AUTH_OIDC_ISSUER"
+	// authOIDCAudienceEnv is the audience the OIDC tokens must be issued for
+	authOIDCAudienceEnv = "AUTH_OIDC_AUDIENCE"
+	// authJWKSURLEnv overrides the JWKS discovered from the OIDC issuer
+	authJWKSURLEnv = "AUTH_JWKS_URL"
+	// authUsernameClaimEnv is the token claim which identifies the user, it defaults to sub
+	authUsernameClaimEnv = "AUTH_USERNAME_CLAIM"
+	// authAPIKeysEnv holds the static API keys as comma separated user:key pairs
+	authAPIKeysEnv = "AUTH_API_KEYS"
+	// authDisabledEnv serves every request as the anonymous user if it is true, no authenticator may be configured then
+	authDisabledEnv = "AUTH_DISABLED"
+
+	// streamTokenTTL is how long a stream token can be used to open a stream, an open stream is not closed when it expires
+	streamTokenTTL = time.Minute
+)
+
+// streamTokens issues the tokens which authenticate the streams of a browser, see auth.StreamTokens
+var streamTokens *auth.StreamTokens
+
+// StreamToken authenticates a GET request in the access_token query parameter, e.g. of an EventSource
+type StreamToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// authMiddleware returns the authentication middleware configured by the environment.
+// It fails without any configured authenticator unless authentication is disabled explicitly.
+func authMiddleware() (func(http.Handler) http.Handler, error) {
+	disabled := false
+	var err error
+	if value := os.Getenv(authDisabledEnv); value != "" {
+		if disabled, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("%s: %v", authDisabledEnv, err)
+		}
+	}
+
+	if streamTokens, err = auth.NewStreamTokens(streamTokenTTL); err != nil {
+		return nil, err
+	}
+
+	var authenticators []auth.Authenticator
+
+	issuer := os.Getenv(authOIDCIssuerEnv)
+	audience := os.Getenv(authOIDCAudienceEnv)
+	usernameClaim := os.Getenv(authUsernameClaimEnv)
+	if jwksURL := os.Getenv(authJWKSURLEnv); jwksURL != "" {
+		authenticators = append(authenticators, auth.NewJWKSAuthenticator(jwksURL, issuer, audience, usernameClaim))
+	} else if issuer != "" {
+		authenticator, err := auth.NewOIDCAuthenticator(context.Background(), issuer, audience, usernameClaim)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, authenticator)
+	}
+
+	if keys := os.Getenv(authAPIKeysEnv); keys != "" {
+		authenticator, err := auth.NewAPIKeyAuthenticator(keys)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, authenticator)
+	}
+
+	switch {
+	case disabled && len(authenticators) > 0:
+		return nil, fmt.Errorf("%s is set, but authenticators are configured", authDisabledEnv)
+	case disabled:
+		log.Printf("authentication is disabled, every request is served as the anonymous user")
+		return func(next http.Handler) http.Handler { return next }, nil
+	case len(authenticators) == 0:
+		return nil, fmt.Errorf("no authenticator is configured, set %s, %s or %s, or %s=true to disable authentication",
+			authOIDCIssuerEnv, authJWKSURLEnv, authAPIKeysEnv, authDisabledEnv)
+	}
+	return auth.Middleware(streamTokens, authenticators...), nil
+}
+
+// postStreamToken issues a stream token of the authenticated user
+func postStreamToken(w http.ResponseWriter, r *http.Request) {
+	token, expires := streamTokens.Issue(auth.UserFromContext(r.Context()))
+	data, err := json.Marshal(StreamToken{Token: token, ExpiresAt: expires})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response to user
+	_, err = w.Write(data)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
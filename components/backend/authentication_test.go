@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/auth"
+)
+
+func TestAuthMiddlewareFailsClosed(t *testing.T) {
+	for _, env := range []string{authOIDCIssuerEnv, authJWKSURLEnv, authAPIKeysEnv, authDisabledEnv} {
+		t.Setenv(env, "")
+	}
+	if _, err := authMiddleware(); err == nil {
+		t.Fatal("expected an error without any configured authenticator")
+	}
+
+	t.Setenv(authDisabledEnv, "yes please")
+	if _, err := authMiddleware(); err == nil {
+		t.Fatalf("expected an error for an invalid %s", authDisabledEnv)
+	}
+
+	t.Setenv(authDisabledEnv, "true")
+	if _, err := authMiddleware(); err != nil {
+		t.Fatalf("expected authentication to be disabled explicitly, got: %v", err)
+	}
+
+	t.Setenv(authAPIKeysEnv, "alice:key1")
+	if _, err := authMiddleware(); err == nil {
+		t.Fatalf("expected an error if %s is set together with an authenticator", authDisabledEnv)
+	}
+
+	t.Setenv(authDisabledEnv, "false")
+	if _, err := authMiddleware(); err != nil {
+		t.Fatalf("expected the API key authenticator, got: %v", err)
+	}
+}
+
+func TestStreamTokenAuthentication(t *testing.T) {
+	for _, env := range []string{authOIDCIssuerEnv, authJWKSURLEnv, authDisabledEnv} {
+		t.Setenv(env, "")
+	}
+	t.Setenv(authAPIKeysEnv, "alice:key1")
+	authenticate, err := authMiddleware()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := mux.NewRouter()
+	r.Use(authenticate)
+	r.HandleFunc("/api/stream-token", postStreamToken).Methods("POST")
+	r.HandleFunc("/api/watch", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(auth.UserFromContext(r.Context())))
+	}).Methods("GET")
+
+	// the EventSource of a browser can not set the Authorization header
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/watch", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without credentials, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/stream-token", nil)
+	req.Header.Set("Authorization", "Bearer key1")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	var token StreamToken
+	if err := json.Unmarshal(rec.Body.Bytes(), &token); rec.Code != http.StatusOK || err != nil || token.Token == "" {
+		t.Fatalf("expected a stream token, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if time.Until(token.ExpiresAt) > streamTokenTTL {
+		t.Fatalf("expected the token to expire within %s, expires at %s", streamTokenTTL, token.ExpiresAt)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/watch?access_token="+url.QueryEscape(token.Token), nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "apikey:alice" {
+		t.Fatalf("expected the stream to be authenticated by the token, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
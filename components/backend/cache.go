@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// CacheStatus is the readiness of the informer cache of a cluster
+type CacheStatus struct {
+	Synced    bool            `json:"synced"`
+	Resources map[string]bool `json:"resources"` // Resources reports for every resource whether its cache is synced
+}
+
+// freshParam reports whether the request bypasses the informer cache with ?fresh=true
+func freshParam(r *http.Request) bool {
+	fresh, _ := strconv.ParseBool(r.URL.Query().Get("fresh"))
+	return fresh
+}
+
+// getCacheStatus starts the informer cache of the cluster if needed and reports whether it is synced.
+// It responds with 503 until the cache is synced, so that it can be used as readiness check.
+func getCacheStatus(w http.ResponseWriter, r *http.Request) {
+	cache := clusterFromContext(r.Context()).Cache
+	cache.Start()
+
+	status := CacheStatus{Synced: cache.HasSynced(), Resources: map[string]bool{}}
+	for resource, synced := range cache.Synced() {
+		status.Resources[resource.Resource] = synced
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !status.Synced {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_, err = w.Write(data)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
@@ -17,12 +17,6 @@ type portForwardAPodRequest struct {
 	ReadyCh    chan struct{}               // ReadyCh communicates when the tunnel is ready to receive traffic
 }
 
-type carry struct {
-	StopCh  chan struct{}              // StopCh is the channel used to manage the port forward lifecycle
-	ReadyCh chan struct{}              // ReadyCh communicates when the tunnel is ready to receive traffic
-	PF      *portforward.PortForwarder // the instance of Portforwarder
-}
-
 type PodOption struct {
 	LocalPort int    // the local port for forwarding
 	PodPort   int    // the k8s pod port
@@ -30,16 +24,29 @@ type PodOption struct {
 }
 
 type Option struct {
-	LocalPort   int    // the local port for forwarding
-	RemotePort  int    // the remote port port for forwarding
-	Namespace   string // the k8s namespace metadata
-	PodName     string // the k8s pod metadata
-	ServiceName string // the k8s service metadata
-	Source      string // the k8s source string, eg: svc/my-nginx-svc po/my-nginx-66b6c48dd5-ttdb2
+	LocalPort   int          // the local port for forwarding
+	RemotePort  int          // the remote port port for forwarding
+	Namespace   string       // the k8s namespace metadata
+	PodName     string       // the k8s pod metadata
+	ServiceName string       // the k8s service metadata
+	Source      string       // the k8s source string, eg: svc/my-nginx-svc po/my-nginx-66b6c48dd5-ttdb2
+	Selection   PodSelection // how the pod of the service is selected among its ready pods
 }
 
+// State is the state of a supervised port forwarding
+type State string
+
+const (
+	StateConnecting State = "connecting" // the pod is resolved and the tunnel is opened
+	StateReady      State = "ready"      // the tunnel is ready to receive traffic
+	StateFailed     State = "failed"     // the tunnel broke, it is reconnected after a backoff
+	StateClosed     State = "closed"     // the port forwarding was closed
+)
+
 type Result struct {
-	Close func()                                        // close the port forwarding
-	Ready func() ([][]portforward.ForwardedPort, error) // block till the forwarding ready
-	Wait  func()                                        // block and listen IOStreams close signal
+	Close     func()                                        // close the port forwarding
+	Ready     func() ([][]portforward.ForwardedPort, error) // block till the forwarding ready or failed
+	Wait      func()                                        // block and listen IOStreams close signal
+	State     func() (State, error)                         // the state of the forwarding and the last error
+	Reconnect func()                                        // reconnect a ready forwarding, e.g. after a request through it failed
 }
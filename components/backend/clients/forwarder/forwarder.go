@@ -3,7 +3,6 @@ package forwarder
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -12,8 +11,8 @@ import (
 	"sync"
 	"syscall"
 
-	"golang.org/x/sync/errgroup"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
 	clientcmd "k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
@@ -21,8 +20,6 @@ import (
 	"k8s.io/client-go/transport/spdy"
 )
 
-var once sync.Once
-
 // It is to forward port whith kubeconfig bytes.
 func WithForwardersEmbedConfig(ctx context.Context, options []*Option, kubeconfigBytes []byte) (*Result, error) {
 	kubeconfigGetter := func() (*clientcmdapi.Config, error) {
@@ -65,13 +62,19 @@ func Forwarders(ctx context.Context, options []*Option, config *restclient.Confi
 }
 
 // It is to forward port for k8s cloud services.
+// Every forwarding is supervised: it is reconnected when the tunnel breaks or its pod is replaced.
 func forwarders(ctx context.Context, options []*Option, config *restclient.Config) (*Result, error) {
 	newOptions, err := parseOptions(options)
 	if err != nil {
 		return nil, err
 	}
 
-	podOptions, err := handleOptions(ctx, newOptions, config)
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	podOptions, err := handleOptions(ctx, newOptions, clientset)
 	if err != nil {
 		return nil, err
 	}
@@ -82,51 +85,28 @@ func forwarders(ctx context.Context, options []*Option, config *restclient.Confi
 		ErrOut: os.Stderr,
 	}
 
-	carries := make([]*carry, len(podOptions))
-
-	var g errgroup.Group
-
+	supervisors := make([]*supervisor, len(podOptions))
 	for index, option := range podOptions {
-		index := index
-		stopCh := make(chan struct{}, 1)
-		readyCh := make(chan struct{})
-
-		req := &portForwardAPodRequest{
-			RestConfig: config,
-			Pod:        option.Pod,
-			LocalPort:  option.LocalPort,
-			PodPort:    option.PodPort,
-			Streams:    stream,
-			StopCh:     stopCh,
-			ReadyCh:    readyCh,
-		}
-		g.Go(func() error {
-			pf, err := portForwardAPod(req)
-			if err != nil {
-				return err
-			}
-			carries[index] = &carry{StopCh: stopCh, ReadyCh: readyCh, PF: pf}
-			return nil
-		})
-	}
-
-	if err := g.Wait(); err != nil {
-		return nil, err
+		supervisors[index] = newSupervisor(config, clientset, newOptions[index], option, stream)
+		go supervisors[index].run()
 	}
 
+	// every result is closed once, the forwarders of other results are not affected
+	var once sync.Once
+	closed := make(chan struct{})
 	ret := &Result{
 		Close: func() {
 			once.Do(func() {
-				for _, c := range carries {
-					close(c.StopCh)
+				close(closed)
+				for _, s := range supervisors {
+					s.close()
 				}
 			})
 		},
 		Ready: func() ([][]portforward.ForwardedPort, error) {
 			pfs := [][]portforward.ForwardedPort{}
-			for _, c := range carries {
-				<-c.ReadyCh
-				ports, err := c.PF.GetPorts()
+			for _, s := range supervisors {
+				ports, err := s.ready()
 				if err != nil {
 					return nil, err
 				}
@@ -134,6 +114,25 @@ func forwarders(ctx context.Context, options []*Option, config *restclient.Confi
 			}
 			return pfs, nil
 		},
+		State: func() (State, error) {
+			// the worst state of the forwardings is reported
+			result, lastErr := StateReady, error(nil)
+			for _, s := range supervisors {
+				state, err := s.status()
+				if err != nil {
+					lastErr = err
+				}
+				if stateRank[state] > stateRank[result] {
+					result = state
+				}
+			}
+			return result, lastErr
+		},
+		Reconnect: func() {
+			for _, s := range supervisors {
+				s.reconnect()
+			}
+		},
 	}
 
 	ret.Wait = func() {
@@ -145,14 +144,20 @@ func forwarders(ctx context.Context, options []*Option, config *restclient.Confi
 	}
 
 	go func() {
-		<-ctx.Done()
-		ret.Close()
+		select {
+		case <-ctx.Done():
+			ret.Close()
+		case <-closed:
+		}
 	}()
 
 	return ret, nil
 }
 
-// It is to forward port, and return the forwarder.
+// stateRank orders the states from the best to the worst
+var stateRank = map[State]int{StateReady: 0, StateConnecting: 1, StateFailed: 2, StateClosed: 3}
+
+// It is to create the port forwarder of a pod, the forwarding is started by ForwardPorts.
 func portForwardAPod(req *portForwardAPodRequest) (*portforward.PortForwarder, error) {
 	targetURL, err := url.Parse(req.RestConfig.Host)
 	if err != nil {
@@ -172,19 +177,7 @@ func portForwardAPod(req *portForwardAPodRequest) (*portforward.PortForwarder, e
 	}
 
 	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, targetURL)
-	fw, err := portforward.New(dialer, []string{fmt.Sprintf("%d:%d", req.LocalPort, req.PodPort)}, req.StopCh, req.ReadyCh, req.Streams.Out, req.Streams.ErrOut)
-	if err != nil {
-		return nil, err
-	}
-
-	go func() {
-		if err := fw.ForwardPorts(); err != nil {
-			log.Printf("failed ForwardPorts: %v", err)
-			//panic(err)
-		}
-	}()
-
-	return fw, nil
+	return portforward.New(dialer, []string{fmt.Sprintf("%d:%d", req.LocalPort, req.PodPort)}, req.StopCh, req.ReadyCh, req.Streams.Out, req.Streams.ErrOut)
 }
 
 // It is to transform kubeconfig bytes to clientcmdapi config.
@@ -0,0 +1,158 @@
+package forwarder
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PodSelection is how the pod of a service is selected among its ready pods
+type PodSelection string
+
+const (
+	SelectFirst      PodSelection = ""            // the first ready pod by name
+	SelectRoundRobin PodSelection = "round-robin" // the next ready pod on every resolve, to spread the forwardings
+	SelectFailover   PodSelection = "failover"    // on a reconnect another ready pod than the broken one
+)
+
+// NoReadyPodError is returned if no pod of a service can be forwarded to
+type NoReadyPodError struct {
+	Namespace string
+	Service   string
+	Rejected  map[string]string // Rejected is the reason why a pod was not chosen by the name of the pod
+}
+
+func (e *NoReadyPodError) Error() string {
+	if len(e.Rejected) == 0 {
+		return fmt.Sprintf("no pods of the service %s in %s", e.Service, e.Namespace)
+	}
+	reasons := make([]string, 0, len(e.Rejected))
+	for pod, reason := range e.Rejected {
+		reasons = append(reasons, fmt.Sprintf("pod %s %s", pod, reason))
+	}
+	sort.Strings(reasons)
+	return fmt.Sprintf("no ready pod of the service %s in %s: %s", e.Service, e.Namespace, strings.Join(reasons, "; "))
+}
+
+// rotation is the index of the next pod of a service for SelectRoundRobin by namespace/service
+var rotation = struct {
+	sync.Mutex
+	next map[string]int
+}{next: map[string]int{}}
+
+// selectServicePod selects a ready pod of the service, the pod named avoid is only selected if it is the only one.
+// A pod is ready if it runs, is not terminating, has the Ready condition and is a ready endpoint of the service.
+func selectServicePod(ctx context.Context, clientset kubernetes.Interface, option *Option, svc *v1.Service, avoid string) (*v1.Pod, error) {
+	if len(svc.Spec.Selector) == 0 {
+		return nil, fmt.Errorf("the service %s in %s has no selector", option.ServiceName, option.Namespace)
+	}
+
+	labels := []string{}
+	for key, val := range svc.Spec.Selector {
+		labels = append(labels, key+"="+val)
+	}
+	sort.Strings(labels)
+	label := strings.Join(labels, ",")
+
+	pods, err := clientset.CoreV1().Pods(option.Namespace).List(ctx, metav1.ListOptions{LabelSelector: label})
+	if err != nil {
+		return nil, err
+	}
+	endpoints := readyEndpoints(ctx, clientset, option)
+
+	noReadyPod := &NoReadyPodError{Namespace: option.Namespace, Service: option.ServiceName, Rejected: map[string]string{}}
+	ready := []v1.Pod{}
+	for _, pod := range pods.Items {
+		reason := podRejection(&pod, true)
+		if reason == "" && endpoints != nil && !endpoints[pod.Name] {
+			reason = "is not a ready endpoint of the service"
+		}
+		if reason != "" {
+			noReadyPod.Rejected[pod.Name] = reason
+			continue
+		}
+		ready = append(ready, pod)
+	}
+	if len(ready) == 0 {
+		return nil, noReadyPod
+	}
+	sort.Slice(ready, func(i, j int) bool { return ready[i].Name < ready[j].Name })
+
+	switch option.Selection {
+	case SelectRoundRobin:
+		key := option.Namespace + "/" + option.ServiceName
+		rotation.Lock()
+		index := rotation.next[key] % len(ready)
+		rotation.next[key] = index + 1
+		rotation.Unlock()
+		return &ready[index], nil
+	case SelectFailover:
+		for i := range ready {
+			if ready[i].Name != avoid {
+				return &ready[i], nil
+			}
+		}
+	}
+	return &ready[0], nil
+}
+
+// readyEndpoints returns the names of the pods which are ready endpoints of the service.
+// It returns nil if the EndpointSlices of the service can not be read, then only the pods are checked.
+func readyEndpoints(ctx context.Context, clientset kubernetes.Interface, option *Option) map[string]bool {
+	slices, err := clientset.DiscoveryV1().EndpointSlices(option.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelServiceName + "=" + option.ServiceName,
+	})
+	if err != nil || len(slices.Items) == 0 {
+		return nil
+	}
+
+	endpoints := map[string]bool{}
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.TargetRef == nil || endpoint.TargetRef.Kind != "Pod" {
+				continue
+			}
+			// an unknown readiness is ready, see EndpointConditions
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			if endpoint.Conditions.Terminating != nil && *endpoint.Conditions.Terminating {
+				continue
+			}
+			endpoints[endpoint.TargetRef.Name] = true
+		}
+	}
+	return endpoints
+}
+
+// podRejection returns why the pod can not be forwarded to or an empty string,
+// the Ready condition is only required if requireReady is set
+func podRejection(pod *v1.Pod, requireReady bool) string {
+	switch {
+	case pod.DeletionTimestamp != nil:
+		return "is terminating"
+	case pod.Status.Phase == "":
+		return "is not running"
+	case pod.Status.Phase != v1.PodRunning:
+		return fmt.Sprintf("is %s", pod.Status.Phase)
+	case requireReady && !podReady(pod):
+		return "is not ready"
+	}
+	return ""
+}
+
+func podReady(pod *v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
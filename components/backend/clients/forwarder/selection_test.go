@@ -0,0 +1,164 @@
+package forwarder
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestService() *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "epp", Namespace: "kyma-system"},
+		Spec:       v1.ServiceSpec{Selector: map[string]string{"app": "epp"}},
+	}
+}
+
+func newTestEndpointSlice(ready map[string]bool) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{ObjectMeta: metav1.ObjectMeta{
+		Name: "epp-abc", Namespace: "kyma-system", Labels: map[string]string{discoveryv1.LabelServiceName: "epp"},
+	}}
+	for pod, isReady := range ready {
+		isReady := isReady
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			TargetRef:  &v1.ObjectReference{Kind: "Pod", Name: pod},
+			Conditions: discoveryv1.EndpointConditions{Ready: &isReady},
+		})
+	}
+	return slice
+}
+
+func TestResolvePodSkipsUnreadyPods(t *testing.T) {
+	unready := newTestPod("epp-a", v1.PodRunning)
+	unready.Status.Conditions[0].Status = v1.ConditionFalse
+	terminating := newTestPod("epp-b", v1.PodRunning)
+	terminating.DeletionTimestamp = &metav1.Time{}
+	clientset := fake.NewSimpleClientset(newTestService(), unready, terminating,
+		newTestPod("epp-c", v1.PodPending), newTestPod("epp-d", v1.PodRunning))
+
+	podOption, err := resolvePod(context.Background(), clientset, &Option{ServiceName: "epp", Namespace: "kyma-system"}, "")
+	if err != nil {
+		t.Fatalf("failed to resolve pod: %v", err)
+	}
+	if podOption.Pod.Name != "epp-d" || podOption.PodPort != 8080 {
+		t.Fatalf("expected the running and ready pod, got: %+v", podOption)
+	}
+}
+
+func TestResolvePodNoReadyPod(t *testing.T) {
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		want    []string
+	}{
+		{name: "no pods", objects: []runtime.Object{newTestService()}, want: []string{"no pods of the service epp"}},
+		{
+			name:    "pending",
+			objects: []runtime.Object{newTestService(), newTestPod("epp-a", v1.PodPending)},
+			want:    []string{"pod epp-a is Pending"},
+		},
+		{
+			name: "not an endpoint",
+			objects: []runtime.Object{newTestService(), newTestPod("epp-a", v1.PodRunning), newTestPod("epp-b", v1.PodRunning),
+				newTestEndpointSlice(map[string]bool{"epp-a": false})},
+			want: []string{"pod epp-a is not a ready endpoint", "pod epp-b is not a ready endpoint"},
+		},
+	}
+
+	for _, tc := range tests {
+		clientset := fake.NewSimpleClientset(tc.objects...)
+		_, err := resolvePod(context.Background(), clientset, &Option{ServiceName: "epp", Namespace: "kyma-system"}, "")
+		var noReadyPod *NoReadyPodError
+		if !errors.As(err, &noReadyPod) {
+			t.Fatalf("%s: expected NoReadyPodError, got: %v", tc.name, err)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Fatalf("%s: expected %q in the error, got: %v", tc.name, want, err)
+			}
+		}
+	}
+}
+
+func TestResolvePodSelection(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newTestService(), newTestPod("epp-a", v1.PodRunning), newTestPod("epp-b", v1.PodRunning),
+		newTestPod("epp-c", v1.PodRunning), newTestEndpointSlice(map[string]bool{"epp-a": true, "epp-b": true, "epp-c": false}))
+
+	resolve := func(selection PodSelection, avoid string) string {
+		podOption, err := resolvePod(context.Background(), clientset, &Option{ServiceName: "epp", Namespace: "kyma-system", Selection: selection}, avoid)
+		if err != nil {
+			t.Fatalf("failed to resolve pod: %v", err)
+		}
+		return podOption.Pod.Name
+	}
+
+	if pod := resolve(SelectFirst, "epp-a"); pod != "epp-a" {
+		t.Fatalf("expected the first ready endpoint, got: %s", pod)
+	}
+	if pod := resolve(SelectFailover, "epp-a"); pod != "epp-b" {
+		t.Fatalf("expected the failover to another ready endpoint, got: %s", pod)
+	}
+
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		seen[resolve(SelectRoundRobin, "")]++
+	}
+	if seen["epp-a"] != 2 || seen["epp-b"] != 2 {
+		t.Fatalf("expected the ready endpoints in turn, got: %v", seen)
+	}
+}
+
+func TestResolvePodPort(t *testing.T) {
+	namedPort := newTestPod("epp-a", v1.PodRunning)
+	namedPort.Spec.Containers[0].Ports = []v1.ContainerPort{{Name: "metrics", ContainerPort: 9090}, {Name: "http", ContainerPort: 8081}}
+	noPorts := newTestPod("epp-a", v1.PodRunning)
+	noPorts.Spec.Containers[0].Ports = nil
+
+	tests := []struct {
+		name     string
+		ports    []v1.ServicePort
+		pod      *v1.Pod
+		option   Option
+		wantPort int
+		wantErr  string
+	}{
+		{name: "given port", option: Option{RemotePort: 3000}, pod: noPorts, wantPort: 3000},
+		{name: "first container port", pod: namedPort, wantPort: 9090},
+		{name: "numeric target port", ports: []v1.ServicePort{{Port: 80, TargetPort: intstr.FromInt(8082)}}, pod: noPorts, wantPort: 8082},
+		{name: "named target port", ports: []v1.ServicePort{{Port: 80, TargetPort: intstr.FromString("http")}}, pod: namedPort, wantPort: 8081},
+		{name: "target port defaults to the port", ports: []v1.ServicePort{{Port: 80}}, pod: noPorts, wantPort: 80},
+		{name: "unknown named target port", ports: []v1.ServicePort{{Port: 80, TargetPort: intstr.FromString("grpc")}}, pod: namedPort, wantErr: "no port named grpc"},
+		{name: "no ports", pod: noPorts, wantErr: "no container ports"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			svc := newTestService()
+			svc.Spec.Ports = tc.ports
+			option := tc.option
+			option.ServiceName, option.Namespace = "epp", "kyma-system"
+
+			podOption, err := resolvePod(context.Background(), fake.NewSimpleClientset(svc, tc.pod), &option, "")
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error %q, got: %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil || podOption.PodPort != tc.wantPort {
+				t.Fatalf("expected port %d, got %+v, %v", tc.wantPort, podOption, err)
+			}
+			if option.RemotePort != tc.option.RemotePort {
+				t.Fatalf("expected the option to be unchanged, got remote port %d", option.RemotePort)
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package forwarder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+)
+
+const (
+	// healthCheckInterval is how often the supervisor checks that the pod of a ready forwarding still runs
+	healthCheckInterval = 10 * time.Second
+	// resolveTimeout limits the requests to the API server to resolve and check the pod
+	resolveTimeout = 10 * time.Second
+
+	minBackoff = 500 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+var (
+	errLostConnection = errors.New("lost connection to pod")
+	errReconnect      = errors.New("reconnect requested")
+	errClosed         = errors.New("port forwarding closed")
+)
+
+// supervisor keeps the port forwarding of one option alive. It reconnects with backoff when the tunnel breaks
+// or the pod is not running anymore, the pod of a service is resolved again before every reconnect.
+type supervisor struct {
+	config    *restclient.Config
+	clientset kubernetes.Interface
+	option    *Option
+	streams   genericclioptions.IOStreams
+
+	stopCh      chan struct{} // stopCh is closed to close the forwarding
+	reconnectCh chan struct{} // reconnectCh asks a ready forwarding to reconnect
+
+	mu        sync.Mutex
+	podOption *PodOption
+	state     State
+	lastErr   error
+	ports     []portforward.ForwardedPort
+	changed   chan struct{} // changed is closed and replaced on every state change
+}
+
+func newSupervisor(config *restclient.Config, clientset kubernetes.Interface, option *Option, podOption *PodOption, streams genericclioptions.IOStreams) *supervisor {
+	return &supervisor{
+		config:      config,
+		clientset:   clientset,
+		option:      option,
+		streams:     streams,
+		stopCh:      make(chan struct{}),
+		reconnectCh: make(chan struct{}, 1),
+		podOption:   podOption,
+		state:       StateConnecting,
+		changed:     make(chan struct{}),
+	}
+}
+
+// run forwards until the supervisor is closed
+func (s *supervisor) run() {
+	defer s.setState(StateClosed, nil, nil)
+
+	backoff := minBackoff
+	for {
+		ready, err := s.forward()
+		select {
+		case <-s.stopCh:
+			return
+		default:
+		}
+
+		if ready {
+			backoff = minBackoff
+		} else {
+			// the local port may be taken by now, the port of the option is used instead
+			s.mu.Lock()
+			s.podOption.LocalPort = s.option.LocalPort
+			s.mu.Unlock()
+		}
+
+		// a requested reconnect skips the backoff
+		if !errors.Is(err, errReconnect) {
+			if err == nil {
+				err = errLostConnection
+			}
+			s.setState(StateFailed, err, nil)
+			log.Printf("port-forward to %s failed, reconnecting in %s: %v", s.target(), backoff, err)
+
+			select {
+			case <-s.stopCh:
+				return
+			case <-time.After(backoff):
+			}
+			backoff = nextBackoff(backoff)
+		}
+
+		s.setState(StateConnecting, nil, nil)
+		for {
+			err := s.resolve()
+			if err == nil {
+				break
+			}
+			s.setState(StateFailed, err, nil)
+			log.Printf("failed to resolve the pod of %s, retrying in %s: %v", s.target(), backoff, err)
+
+			select {
+			case <-s.stopCh:
+				return
+			case <-time.After(backoff):
+			}
+			backoff = nextBackoff(backoff)
+			s.setState(StateConnecting, nil, nil)
+		}
+	}
+}
+
+// forward opens the tunnel to the pod and blocks until it breaks, the pod is not running anymore,
+// a reconnect is requested or the supervisor is closed. ready reports whether the tunnel got ready.
+func (s *supervisor) forward() (ready bool, err error) {
+	s.mu.Lock()
+	podOption := *s.podOption
+	s.mu.Unlock()
+
+	attemptStopCh := make(chan struct{})
+	readyCh := make(chan struct{})
+	pf, err := portForwardAPod(&portForwardAPodRequest{
+		RestConfig: s.config,
+		Pod:        podOption.Pod,
+		LocalPort:  podOption.LocalPort,
+		PodPort:    podOption.PodPort,
+		Streams:    s.streams,
+		StopCh:     attemptStopCh,
+		ReadyCh:    readyCh,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- pf.ForwardPorts()
+	}()
+
+	stop := func(cause error) (bool, error) {
+		close(attemptStopCh)
+		<-errCh
+		return ready, cause
+	}
+
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-readyCh:
+			readyCh = nil
+			ports, err := pf.GetPorts()
+			if err != nil {
+				return stop(err)
+			}
+			ready = true
+			// the local port chosen by the OS is kept, so that the address does not change on reconnects
+			s.mu.Lock()
+			if len(ports) > 0 {
+				s.podOption.LocalPort = int(ports[0].Local)
+			}
+			s.mu.Unlock()
+			s.setState(StateReady, nil, ports)
+		case err := <-errCh:
+			return ready, err
+		case <-s.reconnectCh:
+			return stop(errReconnect)
+		case <-ticker.C:
+			if err := s.checkPod(podOption.Pod); err != nil {
+				return stop(err)
+			}
+		case <-s.stopCh:
+			return stop(nil)
+		}
+	}
+}
+
+// resolve resolves the pod of the option again, the pod of a service may have been replaced
+func (s *supervisor) resolve() error {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	s.mu.Lock()
+	previous := s.podOption.Pod.Name
+	s.mu.Unlock()
+
+	podOption, err := resolvePod(ctx, s.clientset, s.option, previous)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	podOption.LocalPort = s.podOption.LocalPort
+	s.podOption = podOption
+	return nil
+}
+
+// checkPod returns an error if the pod was deleted, does not run anymore or, as pod of a service, is not ready.
+// A failed request to the API server does not break a working tunnel.
+func (s *supervisor) checkPod(pod v1.Pod) error {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	current, err := s.clientset.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		return fmt.Errorf("pod %s was deleted", pod.Name)
+	case err != nil:
+		return nil
+	}
+	if reason := podRejection(current, s.option.ServiceName != ""); reason != "" {
+		return fmt.Errorf("pod %s %s", pod.Name, reason)
+	}
+	return nil
+}
+
+// setState sets the state, the ports of a ready forwarding and the last error if err is not nil
+func (s *supervisor) setState(state State, err error, ports []portforward.ForwardedPort) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setStateLocked(state, err, ports)
+}
+
+func (s *supervisor) setStateLocked(state State, err error, ports []portforward.ForwardedPort) {
+	s.state = state
+	s.ports = ports
+	if err != nil {
+		s.lastErr = err
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// status returns the state and the last error
+func (s *supervisor) status() (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state, s.lastErr
+}
+
+// ready blocks until the forwarding is ready or failed
+func (s *supervisor) ready() ([]portforward.ForwardedPort, error) {
+	for {
+		s.mu.Lock()
+		state, ports, lastErr, changed := s.state, s.ports, s.lastErr, s.changed
+		s.mu.Unlock()
+
+		switch state {
+		case StateReady:
+			return ports, nil
+		case StateFailed:
+			return nil, lastErr
+		case StateClosed:
+			return nil, errClosed
+		}
+		<-changed
+	}
+}
+
+// reconnect reconnects a ready forwarding right away, a forwarding which is not ready is reconnected anyway
+func (s *supervisor) reconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != StateReady {
+		return
+	}
+	s.setStateLocked(StateConnecting, nil, nil)
+	select {
+	case s.reconnectCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *supervisor) close() {
+	close(s.stopCh)
+}
+
+func (s *supervisor) target() string {
+	if s.option.ServiceName != "" {
+		return fmt.Sprintf("svc/%s in %s", s.option.ServiceName, s.option.Namespace)
+	}
+	return fmt.Sprintf("po/%s in %s", s.option.PodName, s.option.Namespace)
+}
+
+func nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
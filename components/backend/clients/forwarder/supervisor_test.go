@@ -0,0 +1,111 @@
+package forwarder
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+)
+
+// newTestPod returns a pod of the epp service, a running pod is ready
+func newTestPod(name string, phase v1.PodPhase) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kyma-system", Labels: map[string]string{"app": "epp"}},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Ports: []v1.ContainerPort{{ContainerPort: 8080}}}}},
+		Status:     v1.PodStatus{Phase: phase},
+	}
+	if phase == v1.PodRunning {
+		pod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}
+	}
+	return pod
+}
+
+func TestSupervisorReadyAndReconnect(t *testing.T) {
+	s := newSupervisor(nil, nil, &Option{ServiceName: "epp", Namespace: "kyma-system"}, &PodOption{}, genericclioptions.IOStreams{})
+
+	readyCh := make(chan []portforward.ForwardedPort)
+	go func() {
+		ports, _ := s.ready()
+		readyCh <- ports
+	}()
+
+	// ready blocks while the forwarding connects
+	select {
+	case <-readyCh:
+		t.Fatal("expected ready to block while connecting")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	s.setState(StateReady, nil, []portforward.ForwardedPort{{Local: 41234, Remote: 8080}})
+	if ports := <-readyCh; len(ports) != 1 || ports[0].Local != 41234 {
+		t.Fatalf("expected the forwarded ports, got: %v", ports)
+	}
+
+	s.reconnect()
+	if state, _ := s.status(); state != StateConnecting || len(s.reconnectCh) != 1 {
+		t.Fatalf("expected a requested reconnect, got state %s", state)
+	}
+	// a forwarding which already reconnects is not asked again
+	s.reconnect()
+	if len(s.reconnectCh) != 1 {
+		t.Fatal("expected only one reconnect request")
+	}
+
+	s.setState(StateFailed, errors.New("lost connection to pod"), nil)
+	if _, err := s.ready(); err == nil || err.Error() != "lost connection to pod" {
+		t.Fatalf("expected the last error of a failed forwarding, got: %v", err)
+	}
+	s.setState(StateConnecting, nil, nil)
+	if state, err := s.status(); state != StateConnecting || err == nil {
+		t.Fatalf("expected the last error to be kept while reconnecting, got %s: %v", state, err)
+	}
+}
+
+func TestSupervisorCheckPod(t *testing.T) {
+	terminating := newTestPod("terminating", v1.PodRunning)
+	terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	clientset := fake.NewSimpleClientset(newTestPod("running", v1.PodRunning), newTestPod("pending", v1.PodPending), terminating)
+	s := newSupervisor(nil, clientset, &Option{ServiceName: "epp", Namespace: "kyma-system"}, &PodOption{}, genericclioptions.IOStreams{})
+
+	for name, healthy := range map[string]bool{"running": true, "pending": false, "terminating": false, "deleted": false} {
+		err := s.checkPod(*newTestPod(name, ""))
+		if healthy != (err == nil) {
+			t.Fatalf("pod %s: expected healthy %v, got: %v", name, healthy, err)
+		}
+	}
+}
+
+func TestSupervisorRunFailsAndCloses(t *testing.T) {
+	config := &restclient.Config{Host: "http://127.0.0.1:1"}
+	podOption := &PodOption{PodPort: 8080, Pod: *newTestPod("epp", v1.PodRunning)}
+	s := newSupervisor(config, fake.NewSimpleClientset(), &Option{PodName: "epp", Namespace: "kyma-system"}, podOption, genericclioptions.IOStreams{})
+	go s.run()
+
+	if _, err := s.ready(); err == nil {
+		t.Fatal("expected the forwarding to fail without an API server")
+	}
+	if state, err := s.status(); state != StateFailed && state != StateConnecting || err == nil {
+		t.Fatalf("expected a failed forwarding which reconnects, got %s: %v", state, err)
+	}
+
+	s.close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if state, _ := s.status(); state == StateClosed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the forwarding to be closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := s.ready(); !errors.Is(err, errClosed) {
+		t.Fatalf("expected ready to fail after close, got: %v", err)
+	}
+}
@@ -3,14 +3,15 @@ package forwarder
 import (
 	"context"
 	"fmt"
+	"log"
 	"strings"
 
 	"golang.org/x/sync/errgroup"
 	v1 "k8s.io/api/core/v1"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
-	restclient "k8s.io/client-go/rest"
 )
 
 func parseSource(source string) (*Option, error) {
@@ -63,11 +64,7 @@ func parseOptions(options []*Option) ([]*Option, error) {
 	return newOptions, nil
 }
 
-func handleOptions(ctx context.Context, options []*Option, config *restclient.Config) ([]*PodOption, error) {
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return nil, err
-	}
+func handleOptions(ctx context.Context, options []*Option, clientset kubernetes.Interface) ([]*PodOption, error) {
 	podOptions := make([]*PodOption, len(options))
 
 	var g errgroup.Group
@@ -77,45 +74,11 @@ func handleOptions(ctx context.Context, options []*Option, config *restclient.Co
 		index := index
 
 		g.Go(func() error {
-			if option.PodName != "" {
-				pod, err := clientset.CoreV1().Pods(option.Namespace).Get(ctx, option.PodName, metav1.GetOptions{})
-				if err != nil {
-					return err
-				}
-				if pod == nil {
-					return fmt.Errorf("no such pod: %v", option.PodName)
-				}
-
-				podOptions[index] = buildPodOption(option, pod)
-				return nil
-			}
-
-			svc, err := clientset.CoreV1().Services(option.Namespace).Get(ctx, option.ServiceName, metav1.GetOptions{})
+			podOption, err := resolvePod(ctx, clientset, option, "")
 			if err != nil {
 				return err
 			}
-			if svc == nil {
-				return fmt.Errorf("no such service: %+v", option.ServiceName)
-			}
-
-			labels := []string{}
-			for key, val := range svc.Spec.Selector {
-				labels = append(labels, key+"="+val)
-			}
-			label := strings.Join(labels, ",")
-
-			pods, err := clientset.CoreV1().Pods(option.Namespace).List(ctx, metav1.ListOptions{LabelSelector: label, Limit: 1})
-			if err != nil {
-				return err
-			}
-			if len(pods.Items) == 0 {
-				return fmt.Errorf("no such pods of the service of %v", option.ServiceName)
-			}
-			pod := pods.Items[0]
-
-			fmt.Printf("Forwarding service: %v to pod %v ...\n", option.ServiceName, pod.Name)
-
-			podOptions[index] = buildPodOption(option, &pod)
+			podOptions[index] = podOption
 			return nil
 		})
 	}
@@ -126,19 +89,87 @@ func handleOptions(ctx context.Context, options []*Option, config *restclient.Co
 	return podOptions, nil
 }
 
-func buildPodOption(option *Option, pod *v1.Pod) *PodOption {
-	if option.RemotePort == 0 {
-		option.RemotePort = int(pod.Spec.Containers[0].Ports[0].ContainerPort)
+// resolvePod returns the pod of the option, the pod of a service is selected among its ready pods,
+// see selectServicePod. The pod named avoid is only selected by SelectFailover if no other pod is ready.
+func resolvePod(ctx context.Context, clientset kubernetes.Interface, option *Option, avoid string) (*PodOption, error) {
+	if option.PodName != "" {
+		pod, err := clientset.CoreV1().Pods(option.Namespace).Get(ctx, option.PodName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if pod == nil {
+			return nil, fmt.Errorf("no such pod: %v", option.PodName)
+		}
+
+		return buildPodOption(option, pod, nil)
+	}
+
+	svc, err := clientset.CoreV1().Services(option.Namespace).Get(ctx, option.ServiceName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if svc == nil {
+		return nil, fmt.Errorf("no such service: %+v", option.ServiceName)
+	}
+
+	pod, err := selectServicePod(ctx, clientset, option, svc, avoid)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("forwarding service %s to pod %s", option.ServiceName, pod.Name)
+
+	return buildPodOption(option, pod, svc)
+}
+
+// buildPodOption returns the forwarding to the pod, the port defaults to defaultPodPort
+func buildPodOption(option *Option, pod *v1.Pod, svc *v1.Service) (*PodOption, error) {
+	port := option.RemotePort
+	if port == 0 {
+		var err error
+		if port, err = defaultPodPort(pod, svc); err != nil {
+			return nil, err
+		}
 	}
 
 	return &PodOption{
 		LocalPort: option.LocalPort,
-		PodPort:   option.RemotePort,
+		PodPort:   port,
 		Pod: v1.Pod{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      pod.Name,
 				Namespace: pod.Namespace,
 			},
 		},
+	}, nil
+}
+
+// defaultPodPort returns the target port of the first port of the service,
+// or the first container port of the pod if there is no service port
+func defaultPodPort(pod *v1.Pod, svc *v1.Service) (int, error) {
+	if svc != nil && len(svc.Spec.Ports) > 0 {
+		servicePort := svc.Spec.Ports[0]
+		if servicePort.TargetPort.Type == intstr.String {
+			for _, container := range pod.Spec.Containers {
+				for _, port := range container.Ports {
+					if port.Name == servicePort.TargetPort.StrVal {
+						return int(port.ContainerPort), nil
+					}
+				}
+			}
+			return 0, fmt.Errorf("pod %s has no port named %s", pod.Name, servicePort.TargetPort.StrVal)
+		}
+		if servicePort.TargetPort.IntVal != 0 {
+			return int(servicePort.TargetPort.IntVal), nil
+		}
+		// the target port defaults to the port of the service
+		return int(servicePort.Port), nil
+	}
+
+	for _, container := range pod.Spec.Containers {
+		if len(container.Ports) > 0 {
+			return int(container.Ports[0].ContainerPort), nil
+		}
 	}
+	return 0, fmt.Errorf("no remote port given and pod %s has no container ports", pod.Name)
 }
@@ -1,17 +1,10 @@
 package function
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"io"
 	"log"
 
-	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/util/retry"
 
@@ -24,10 +17,29 @@ import (
 
 type Client struct {
 	client dynamic.Interface
+	lister Lister
+}
+
+// Lister lists the functions from a cache, an empty namespace lists all namespaces
+type Lister interface {
+	List(namespace string) (*unstructured.UnstructuredList, error)
 }
 
 func NewClient(client dynamic.Interface) Client {
-	return Client{client}
+	return Client{client: client}
+}
+
+// WithLister returns a copy of the client which lists the functions from the cache of the lister.
+// The API server is listed if the cache fails.
+func (c Client) WithLister(lister Lister) Client {
+	c.lister = lister
+	return c
+}
+
+// Fresh returns a copy of the client which bypasses the cache and lists from the API server
+func (c Client) Fresh() Client {
+	c.lister = nil
+	return c
 }
 
 func (c Client) GetFnJson(name, namespace string) (*unstructured.Unstructured, error) {
@@ -40,34 +52,62 @@ func (c Client) GetFnJson(name, namespace string) (*unstructured.Unstructured, e
 	return functionUnstructured, nil
 }
 
-func (c Client) UpdateFunction(fn serverlessv1alpha1.Function) (*unstructured.Unstructured, error) {
+// GetFn returns the function in the namespace
+func (c Client) GetFn(name, namespace string) (*serverlessv1alpha1.Function, error) {
+	functionUnstructured, err := c.GetFnJson(name, namespace)
+	if err != nil {
+		return nil, err
+	}
 
-	mapInterfaceFn, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&fn)
+	fn := new(serverlessv1alpha1.Function)
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(functionUnstructured.Object, fn)
 	if err != nil {
 		return nil, err
 	}
+	return fn, nil
+}
 
+// UpdateFunction changes the latest version of the function by update and writes it back.
+// On conflicts the function is fetched again and update is applied to it once more,
+// so that concurrent changes are not overwritten.
+func (c Client) UpdateFunction(name, namespace string, update func(fn *serverlessv1alpha1.Function) error) (*unstructured.Unstructured, error) {
+	var updated *unstructured.Unstructured
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 
-		result, getErr := c.GetFnJson(fn.Name, fn.Namespace)
+		result, getErr := c.GetFnJson(name, namespace)
 		if getErr != nil {
 			log.Printf("failed to get latest version of function: %v", getErr)
+			return getErr
+		}
+
+		fn := new(serverlessv1alpha1.Function)
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(result.Object, fn); err != nil {
+			return err
+		}
+		if err := update(fn); err != nil {
 			return err
 		}
 
+		mapInterfaceFn, err := runtime.DefaultUnstructuredConverter.ToUnstructured(fn)
+		if err != nil {
+			return err
+		}
+		// only the spec, labels and annotations are written, the fetched object keeps its resource version
 		if err := unstructured.SetNestedField(result.Object, mapInterfaceFn["spec"], "spec"); err != nil {
 			return err
 		}
+		result.SetLabels(fn.Labels)
+		result.SetAnnotations(fn.Annotations)
 
-		_, updateErr := c.client.Resource(GroupVersionResource()).Namespace(fn.Namespace).Update(context.Background(), result, metav1.UpdateOptions{})
+		var updateErr error
+		updated, updateErr = c.client.Resource(GroupVersionResource()).Namespace(namespace).Update(context.Background(), result, metav1.UpdateOptions{})
 		return updateErr
 	})
 
 	if retryErr != nil {
-		return nil, err
+		return nil, retryErr
 	}
-
-	return c.GetFnJson(fn.Name, fn.Namespace)
+	return updated, nil
 }
 
 func GroupVersionResource() schema.GroupVersionResource {
@@ -109,6 +149,14 @@ func (c Client) DeleteFunction(name, namespace string) error {
 }
 
 func (c Client) List(namespace string) (*serverlessv1alpha1.FunctionList, error) {
+	if c.lister != nil {
+		functionUnstructured, err := c.lister.List(namespace)
+		if err == nil {
+			return toFunctionList(functionUnstructured)
+		}
+		log.Printf("failed to list functions from the cache, falling back to the API server: %v", err)
+	}
+
 	functionUnstructured, err := c.client.Resource(GroupVersionResource()).Namespace(namespace).List(
 		context.Background(), metav1.ListOptions{})
 
@@ -141,75 +189,6 @@ type TinyFunction struct {
 	Source    string `json:"source"`
 }
 
-func (c Client) GetFunctionLogs(name, namespace string, k8sConfig *rest.Config) (map[string]string, error) {
-	var logsData = make(map[string]string)
-
-	// creates the clientset
-	clientset, err := kubernetes.NewForConfig(k8sConfig)
-	if err != nil {
-		return logsData, err
-	}
-
-	// get the pods by label filter
-	labelSelector := metav1.LabelSelector{
-		MatchLabels: map[string]string{
-			"serverless.kyma-project.io/function-name": name,
-			"serverless.kyma-project.io/resource":      "deployment",
-		},
-	}
-	listOptions := metav1.ListOptions{
-		LabelSelector: labels.Set(labelSelector.MatchLabels).String(),
-	}
-	podList, err := clientset.CoreV1().Pods(namespace).List(context.Background(), listOptions)
-	if err != nil {
-		return logsData, err
-	}
-
-	// Fetch logs for each pod
-	for _, pod := range podList.Items {
-		podName := pod.ObjectMeta.Name
-		podLogs, err := c.GetPodLogs(podName, namespace, k8sConfig)
-		if err != nil {
-			return logsData, err
-		}
-		logsData[podName] = podLogs
-	}
-
-	return logsData, nil
-}
-
-func (c Client) GetPodLogs(name, namespace string, k8sConfig *rest.Config) (string, error) {
-
-	// creates the clientset
-	clientset, err := kubernetes.NewForConfig(k8sConfig)
-	if err != nil {
-		return "", err
-	}
-
-	count := int64(300)
-	podLogOpts := v1.PodLogOptions{
-		Container: "function",
-		Follow:    false,
-		TailLines: &count,
-	}
-
-	req := clientset.CoreV1().Pods(namespace).GetLogs(name, &podLogOpts)
-	podLogs, err := req.Stream(context.Background())
-	if err != nil {
-		return "", err
-	}
-	defer podLogs.Close()
-
-	buf := new(bytes.Buffer)
-	_, err = io.Copy(buf, podLogs)
-	if err != nil {
-		return "", err
-	}
-	str := buf.String()
-
-	return str, nil
-}
-
 func toFunctionList(unstructuredList *unstructured.UnstructuredList) (*serverlessv1alpha1.FunctionList, error) {
 	functionList := new(serverlessv1alpha1.FunctionList)
 	functionListBytes, err := unstructuredList.MarshalJSON()
@@ -0,0 +1,208 @@
+package function
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultContainer is the container of the function pods which runs the function
+	DefaultContainer = "function"
+	// DefaultTailLines is the number of lines returned per pod if neither tail lines nor since seconds are given
+	DefaultTailLines = int64(300)
+
+	// maxLogLineSize is the size of the longest log line, the logs of a pod end with an error at a longer line
+	maxLogLineSize = 256 * 1024
+)
+
+// podRelistInterval is how often the pods of the function are listed while following their logs, e.g. to pick up scaled out pods
+var podRelistInterval = 5 * time.Second
+
+// LogOptions selects the logs of the function pods
+type LogOptions struct {
+	Pod          string // Pod restricts the logs to a single pod of the function
+	Container    string // Container defaults to DefaultContainer
+	Follow       bool
+	SinceSeconds *int64
+	TailLines    *int64
+	Timestamps   bool
+	Previous     bool // Previous returns the logs of the terminated container, e.g. of a crash loop
+}
+
+// LogLine is a line of the logs of a function pod, Error is set instead of Line if the logs of the pod failed
+type LogLine struct {
+	Pod   string `json:"pod"`
+	Line  string `json:"line,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// PodSelector returns the label selector of the pods running the function
+func PodSelector(name string) string {
+	return labels.Set{
+		"serverless.kyma-project.io/function-name": name,
+		"serverless.kyma-project.io/resource":      "deployment",
+	}.String()
+}
+
+// FunctionPods returns the names of the pods running the function sorted by name, e.g. none while it is scaled to zero.
+// Only the given pod is returned if it belongs to the function.
+func (c Client) FunctionPods(ctx context.Context, clientset kubernetes.Interface, name, namespace, pod string) ([]string, error) {
+	podList, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: PodSelector(name)})
+	if err != nil {
+		return nil, err
+	}
+
+	pods := []string{}
+	for _, item := range podList.Items {
+		if pod == "" || item.Name == pod {
+			pods = append(pods, item.Name)
+		}
+	}
+	if len(pods) == 0 && pod != "" {
+		return nil, fmt.Errorf("pod %s is not a pod of function %s", pod, name)
+	}
+	sort.Strings(pods)
+	return pods, nil
+}
+
+// GetFunctionLogs returns the logs of all pods of the function by pod name, Follow is ignored
+func (c Client) GetFunctionLogs(ctx context.Context, clientset kubernetes.Interface, name, namespace string, opts LogOptions) (map[string]string, error) {
+	pods, err := c.FunctionPods(ctx, clientset, name, namespace, opts.Pod)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Follow = false
+	logsData := make(map[string]string, len(pods))
+	for _, pod := range pods {
+		podLogs, err := c.GetPodLogs(ctx, clientset, pod, namespace, opts)
+		if err != nil {
+			return nil, fmt.Errorf("pod %s: %w", pod, err)
+		}
+		logsData[pod] = podLogs
+	}
+	return logsData, nil
+}
+
+// GetPodLogs returns the logs of the pod
+func (c Client) GetPodLogs(ctx context.Context, clientset kubernetes.Interface, name, namespace string, opts LogOptions) (string, error) {
+	podLogs, err := clientset.CoreV1().Pods(namespace).GetLogs(name, podLogOptions(opts)).Stream(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer podLogs.Close()
+
+	buf := new(bytes.Buffer)
+	_, err = io.Copy(buf, podLogs)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// StreamFunctionLogs sends the log lines of all pods of the function to the channel, the lines of the pods are merged
+// in the order they are read. A pod whose logs fail is reported by a line with Error, the other pods are streamed on.
+// It returns when the logs of all pods are read, with Follow when the context is cancelled.
+// With Follow the pods are listed every podRelistInterval, so that the logs of pods started later are streamed as well.
+func (c Client) StreamFunctionLogs(ctx context.Context, clientset kubernetes.Interface, name, namespace string, opts LogOptions, lines chan<- LogLine) error {
+	pods, err := c.FunctionPods(ctx, clientset, name, namespace, opts.Pod)
+	if err != nil {
+		return err
+	}
+
+	send := func(line LogLine) bool {
+		select {
+		case lines <- line:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var wg sync.WaitGroup
+	streamed := make(map[string]bool)
+	stream := func(pods []string) {
+		for _, pod := range pods {
+			if streamed[pod] {
+				continue
+			}
+			streamed[pod] = true
+
+			wg.Add(1)
+			go func(pod string) {
+				defer wg.Done()
+				streamPodLogs(ctx, clientset, pod, namespace, opts, send)
+			}(pod)
+		}
+	}
+	stream(pods)
+
+	if opts.Follow && opts.Pod == "" {
+		ticker := time.NewTicker(podRelistInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+				return nil
+			case <-ticker.C:
+				// the pods are listed again on the next tick if the list fails
+				if pods, err := c.FunctionPods(ctx, clientset, name, namespace, ""); err == nil {
+					stream(pods)
+				}
+			}
+		}
+	}
+	wg.Wait()
+	return nil
+}
+
+// streamPodLogs sends the log lines of the pod until its logs are read or send fails
+func streamPodLogs(ctx context.Context, clientset kubernetes.Interface, pod, namespace string, opts LogOptions, send func(LogLine) bool) {
+	podLogs, err := clientset.CoreV1().Pods(namespace).GetLogs(pod, podLogOptions(opts)).Stream(ctx)
+	if err != nil {
+		send(LogLine{Pod: pod, Error: err.Error()})
+		return
+	}
+	defer podLogs.Close()
+
+	scanner := bufio.NewScanner(podLogs)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+	for scanner.Scan() {
+		if !send(LogLine{Pod: pod, Line: scanner.Text()}) {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		send(LogLine{Pod: pod, Error: err.Error()})
+	}
+}
+
+func podLogOptions(opts LogOptions) *v1.PodLogOptions {
+	if opts.Container == "" {
+		opts.Container = DefaultContainer
+	}
+	if opts.TailLines == nil && opts.SinceSeconds == nil {
+		tailLines := DefaultTailLines
+		opts.TailLines = &tailLines
+	}
+	return &v1.PodLogOptions{
+		Container:    opts.Container,
+		Follow:       opts.Follow,
+		SinceSeconds: opts.SinceSeconds,
+		TailLines:    opts.TailLines,
+		Timestamps:   opts.Timestamps,
+		Previous:     opts.Previous,
+	}
+}
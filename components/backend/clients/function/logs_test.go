@@ -0,0 +1,77 @@
+package function
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newFunctionPod(name string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      name,
+		Namespace: "default",
+		Labels: map[string]string{
+			"serverless.kyma-project.io/function-name": "orders",
+			"serverless.kyma-project.io/resource":      "deployment",
+		},
+	}}
+}
+
+func TestStreamFunctionLogsFollowsNewPods(t *testing.T) {
+	defer func(original time.Duration) { podRelistInterval = original }(podRelistInterval)
+	podRelistInterval = 10 * time.Millisecond
+
+	clientset := fake.NewSimpleClientset(newFunctionPod("orders-a"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lines := make(chan LogLine)
+	done := make(chan error, 1)
+	go func() {
+		done <- Client{}.StreamFunctionLogs(ctx, clientset, "orders", "default", LogOptions{Follow: true}, lines)
+	}()
+
+	// the fake clientset returns the same logs for every pod
+	next := func() LogLine {
+		select {
+		case line := <-lines:
+			return line
+		case err := <-done:
+			t.Fatalf("expected the stream to follow the pods, it ended with: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a log line")
+		}
+		return LogLine{}
+	}
+	if line := next(); line.Pod != "orders-a" || line.Line != "fake logs" {
+		t.Fatalf("expected the logs of the existing pod, got: %+v", line)
+	}
+
+	if _, err := clientset.CoreV1().Pods("default").Create(ctx, newFunctionPod("orders-b"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if line := next(); line.Pod != "orders-b" || line.Line != "fake logs" {
+		t.Fatalf("expected the logs of the new pod, got: %+v", line)
+	}
+
+	// the logs of a pod are streamed once
+	select {
+	case line := <-lines:
+		t.Fatalf("expected no more lines, got: %+v", line)
+	case <-time.After(5 * podRelistInterval):
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected the stream to end without error, got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stream to end with the context")
+	}
+}
@@ -0,0 +1,167 @@
+package gitrepository
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	serverlessv1alpha1 "github.com/kyma-project/kyma/components/function-controller/pkg/apis/serverless/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+// Client struct for Kyma GitRepository client
+type Client struct {
+	client dynamic.Interface
+}
+
+// NewClient creates and returns new client for Kyma GitRepositories
+func NewClient(client dynamic.Interface) Client {
+	return Client{client: client}
+}
+
+// List returns the list of git repositories in specified namespace
+// or returns an error if it fails for any reason
+func (c Client) List(namespace string) (*serverlessv1alpha1.GitRepositoryList, error) {
+
+	repositoriesUnstructured, err := c.client.Resource(GroupVersionResource()).Namespace(namespace).List(
+		context.Background(), metav1.ListOptions{})
+
+	if err != nil {
+		return nil, err
+	}
+	return toGitRepositoryList(repositoriesUnstructured)
+}
+
+// GetJson returns the git repository in specified namespace as JSON
+// or returns an error if it fails for any reason
+func (c Client) GetJson(name, namespace string) (*unstructured.Unstructured, error) {
+
+	repositoryUnstructured, err := c.client.Resource(GroupVersionResource()).Namespace(namespace).Get(
+		context.Background(), name, metav1.GetOptions{})
+
+	if err != nil {
+		return nil, err
+	}
+	return repositoryUnstructured, nil
+}
+
+// Get returns the git repository in specified namespace
+// or returns an error if it fails for any reason
+func (c Client) Get(name, namespace string) (*serverlessv1alpha1.GitRepository, error) {
+
+	repositoryUnstructured, err := c.GetJson(name, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	repository := new(serverlessv1alpha1.GitRepository)
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(repositoryUnstructured.Object, repository)
+	if err != nil {
+		return nil, err
+	}
+	return repository, nil
+}
+
+// Create creates a new git repository in specified namespace
+// or returns an error if it fails for any reason
+func (c Client) Create(repository serverlessv1alpha1.GitRepository) (*unstructured.Unstructured, error) {
+
+	repository.APIVersion = serverlessv1alpha1.GroupVersion.String()
+	repository.Kind = "GitRepository"
+	mapInterfaceRepository, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&repository)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.client.Resource(GroupVersionResource()).Namespace(repository.Namespace).Create(
+		context.Background(), &unstructured.Unstructured{Object: mapInterfaceRepository}, metav1.CreateOptions{})
+}
+
+// Update replaces the spec of the git repository in specified namespace, the update is retried on conflicts
+// or returns an error if it fails for any reason
+func (c Client) Update(repository serverlessv1alpha1.GitRepository) (*unstructured.Unstructured, error) {
+	return c.UpdateIf(repository, nil)
+}
+
+// UpdateIf updates the git repository like Update if precondition accepts its latest version,
+// the error of the precondition is returned otherwise
+func (c Client) UpdateIf(repository serverlessv1alpha1.GitRepository, precondition func(current *unstructured.Unstructured) error) (*unstructured.Unstructured, error) {
+
+	mapInterfaceRepository, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&repository)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated *unstructured.Unstructured
+	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		result, getErr := c.GetJson(repository.Name, repository.Namespace)
+		if getErr != nil {
+			log.Printf("failed to get latest version of git repository: %v", getErr)
+			return getErr
+		}
+		if precondition != nil {
+			if err := precondition(result); err != nil {
+				return err
+			}
+		}
+
+		if err := unstructured.SetNestedField(result.Object, mapInterfaceRepository["spec"], "spec"); err != nil {
+			return err
+		}
+		// the given labels are added to the labels of the git repository
+		if len(repository.Labels) > 0 {
+			labels := result.GetLabels()
+			if labels == nil {
+				labels = make(map[string]string, len(repository.Labels))
+			}
+			for key, value := range repository.Labels {
+				labels[key] = value
+			}
+			result.SetLabels(labels)
+		}
+
+		var updateErr error
+		updated, updateErr = c.client.Resource(GroupVersionResource()).Namespace(repository.Namespace).Update(
+			context.Background(), result, metav1.UpdateOptions{})
+		return updateErr
+	})
+
+	if retryErr != nil {
+		return nil, retryErr
+	}
+	return updated, nil
+}
+
+// Delete deletes the git repository in specified namespace
+// or returns an error if it fails for any reason
+func (c Client) Delete(name, namespace string) error {
+	return c.client.Resource(GroupVersionResource()).Namespace(namespace).Delete(
+		context.Background(), name, metav1.DeleteOptions{})
+}
+
+// GroupVersionResource returns the GVR of the Kyma GitRepository
+func GroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Version:  serverlessv1alpha1.GroupVersion.Version,
+		Group:    serverlessv1alpha1.GroupVersion.Group,
+		Resource: "gitrepositories",
+	}
+}
+
+func toGitRepositoryList(unstructuredList *unstructured.UnstructuredList) (*serverlessv1alpha1.GitRepositoryList, error) {
+	repositoryList := new(serverlessv1alpha1.GitRepositoryList)
+	repositoryListBytes, err := unstructuredList.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(repositoryListBytes, repositoryList)
+	if err != nil {
+		return nil, err
+	}
+	return repositoryList, nil
+}
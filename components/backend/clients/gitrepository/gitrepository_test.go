@@ -0,0 +1,72 @@
+package gitrepository
+
+import (
+	"testing"
+
+	serverlessv1alpha1 "github.com/kyma-project/kyma/components/function-controller/pkg/apis/serverless/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newFakeClient() Client {
+	return NewClient(dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{GroupVersionResource(): "GitRepositoryList"}))
+}
+
+func newRepository(name, url string) serverlessv1alpha1.GitRepository {
+	repository := serverlessv1alpha1.GitRepository{
+		Spec: serverlessv1alpha1.GitRepositorySpec{URL: url},
+	}
+	repository.Name = name
+	repository.Namespace = "default"
+	return repository
+}
+
+func TestClient(t *testing.T) {
+	client := newFakeClient()
+
+	repository := newRepository("functions", "https://github.com/kyma-project/examples.git")
+	if _, err := client.Create(repository); err != nil {
+		t.Fatalf("failed to create git repository: %v", err)
+	}
+	if _, err := client.Create(repository); !apierrors.IsAlreadyExists(err) {
+		t.Fatalf("expected an already exists error, got: %v", err)
+	}
+
+	repository.Spec.URL = "git@github.com:kyma-project/examples.git"
+	repository.Spec.Auth = &serverlessv1alpha1.RepositoryAuth{Type: serverlessv1alpha1.RepositoryAuthSSHKey, SecretName: "git-creds"}
+	if _, err := client.Update(repository); err != nil {
+		t.Fatalf("failed to update git repository: %v", err)
+	}
+
+	updated, err := client.Get("functions", "default")
+	if err != nil {
+		t.Fatalf("failed to get git repository: %v", err)
+	}
+	if updated.Spec.URL != repository.Spec.URL || updated.Spec.Auth == nil || updated.Spec.Auth.SecretName != "git-creds" {
+		t.Fatalf("expected the spec to be updated, got: %+v", updated.Spec)
+	}
+	if updated.Kind != "GitRepository" || updated.APIVersion != "serverless.kyma-project.io/v1alpha1" {
+		t.Fatalf("unexpected type meta: %+v", updated.TypeMeta)
+	}
+
+	if _, err := client.Create(newRepository("other", "https://github.com/kyma-project/kyma.git")); err != nil {
+		t.Fatalf("failed to create git repository: %v", err)
+	}
+	list, err := client.List("default")
+	if err != nil || len(list.Items) != 2 {
+		t.Fatalf("expected 2 git repositories, got: %v, %v", list, err)
+	}
+
+	if err := client.Delete("functions", "default"); err != nil {
+		t.Fatalf("failed to delete git repository: %v", err)
+	}
+	if _, err := client.Get("functions", "default"); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected a not found error, got: %v", err)
+	}
+	if _, err := client.Update(newRepository("functions", "https://github.com/kyma-project/examples.git")); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected a not found error for an update of a deleted repository, got: %v", err)
+	}
+}
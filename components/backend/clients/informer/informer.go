@@ -0,0 +1,287 @@
+package informer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// SyncTimeout is how long a list waits for the cache of a resource to sync after it was started
+	SyncTimeout = 10 * time.Second
+	// syncPollInterval is the interval to check whether the cache is synced
+	syncPollInterval = 100 * time.Millisecond
+)
+
+var (
+	// ErrNotSynced is returned when the cache of a resource did not sync in time
+	ErrNotSynced = errors.New("informer cache is not synced")
+	// ErrClosed is returned when listing from a closed cache
+	ErrClosed = errors.New("informer cache is closed")
+)
+
+// Cache is a shared informer cache for the resources of a cluster in all namespaces.
+// The informers are started with the first list or watch, so that unused clusters do not watch the API server.
+// A resource whose informer fails to list, e.g. because a namespace-scoped kubeconfig must not watch all namespaces,
+// or does not sync in time is not waited for again, its lists fail right away until the informer syncs.
+type Cache struct {
+	factory   dynamicinformer.DynamicSharedInformerFactory
+	informers map[schema.GroupVersionResource]informers.GenericInformer
+
+	errMu sync.Mutex
+	errs  map[schema.GroupVersionResource]error // errs is the last error of the informer by resource
+
+	// watchMu guards the watchers, the informers of this client-go version can not remove their event handlers,
+	// so every informer has one handler which passes the changes on to the watchers of its resource
+	watchMu  sync.RWMutex
+	watchers map[schema.GroupVersionResource]map[*watcher]struct{}
+
+	startOnce sync.Once
+	closeOnce sync.Once
+	stop      chan struct{}
+}
+
+// New creates a cache for the given resources of the cluster, it is started with the first list
+func New(client dynamic.Interface, resources ...schema.GroupVersionResource) *Cache {
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(client, 0)
+	c := &Cache{
+		factory:   factory,
+		informers: make(map[schema.GroupVersionResource]informers.GenericInformer),
+		errs:      make(map[schema.GroupVersionResource]error),
+		watchers:  make(map[schema.GroupVersionResource]map[*watcher]struct{}),
+		stop:      make(chan struct{}),
+	}
+	for _, resource := range resources {
+		resource := resource
+		informer := factory.ForResource(resource)
+		// the handler can only fail if the informer is already started
+		_ = informer.Informer().SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+			c.setErr(resource, err)
+			cache.DefaultWatchErrorHandler(r, err)
+		})
+		informer.Informer().AddEventHandler(c.dispatcher(resource))
+		c.informers[resource] = informer
+		c.watchers[resource] = make(map[*watcher]struct{})
+	}
+	return c
+}
+
+func (c *Cache) setErr(resource schema.GroupVersionResource, err error) {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	c.errs[resource] = err
+}
+
+// err returns the last error of the informer of the resource, it is nil once the informer is synced
+func (c *Cache) err(resource schema.GroupVersionResource) error {
+	if c.informers[resource].Informer().HasSynced() {
+		return nil
+	}
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	return c.errs[resource]
+}
+
+// Start starts the informers, it is safe to call it multiple times
+func (c *Cache) Start() {
+	c.startOnce.Do(func() {
+		c.factory.Start(c.stop)
+	})
+}
+
+// HasSynced reports whether the caches of all resources are synced
+func (c *Cache) HasSynced() bool {
+	for _, informer := range c.informers {
+		if !informer.Informer().HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// Synced reports for every resource whether its cache is synced
+func (c *Cache) Synced() map[schema.GroupVersionResource]bool {
+	synced := make(map[schema.GroupVersionResource]bool, len(c.informers))
+	for resource, informer := range c.informers {
+		synced[resource] = informer.Informer().HasSynced()
+	}
+	return synced
+}
+
+// Close stops the informers, it is safe to call it on a nil cache
+func (c *Cache) Close() {
+	if c == nil {
+		return
+	}
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+// Lister returns the lister of the resource, the resource must be one of the cached resources
+func (c *Cache) Lister(resource schema.GroupVersionResource) *Lister {
+	return &Lister{cache: c, resource: resource}
+}
+
+// Lister lists a resource from the cache
+type Lister struct {
+	cache    *Cache
+	resource schema.GroupVersionResource
+}
+
+// List returns the cached resources of the namespace sorted by namespace and name, an empty namespace lists all namespaces.
+// The cache is started if needed, ErrNotSynced is returned if it does not sync within SyncTimeout.
+// The error of an informer which failed to list, e.g. Forbidden, is returned as soon as it occurs.
+func (l *Lister) List(namespace string) (*unstructured.UnstructuredList, error) {
+	if err := l.waitForSync(); err != nil {
+		return nil, err
+	}
+	return l.list(namespace)
+}
+
+// Watch returns the cached resources of the namespace like List and calls the handler for their changes until stop is called.
+// The handler is called by the informer which is shared with the other watches, so it must not block.
+// A change which is already part of the returned list may be passed to the handler once more.
+func (l *Lister) Watch(namespace string, handler cache.ResourceEventHandler) (list *unstructured.UnstructuredList, stop func(), err error) {
+	if err := l.waitForSync(); err != nil {
+		return nil, nil, err
+	}
+
+	// the list and the registration are not interleaved with a change, so that no change is missed
+	l.cache.watchMu.Lock()
+	defer l.cache.watchMu.Unlock()
+	list, err = l.list(namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w := &watcher{namespace: namespace, handler: handler}
+	l.cache.watchers[l.resource][w] = struct{}{}
+
+	stop = func() {
+		l.cache.watchMu.Lock()
+		defer l.cache.watchMu.Unlock()
+		delete(l.cache.watchers[l.resource], w)
+	}
+	return list, stop, nil
+}
+
+// waitForSync starts the cache if needed and waits until the informer of the resource is synced
+func (l *Lister) waitForSync() error {
+	select {
+	case <-l.cache.stop:
+		return ErrClosed
+	default:
+	}
+
+	informer, ok := l.cache.informers[l.resource]
+	if !ok {
+		return errors.New("resource is not cached: " + l.resource.String())
+	}
+
+	l.cache.Start()
+	err := wait.PollImmediate(syncPollInterval, SyncTimeout, func() (bool, error) {
+		select {
+		case <-l.cache.stop:
+			return false, ErrClosed
+		default:
+		}
+		if err := l.cache.err(l.resource); err != nil {
+			return false, fmt.Errorf("informer cache of %s failed: %w", l.resource.Resource, err)
+		}
+		return informer.Informer().HasSynced(), nil
+	})
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		// the next lists do not wait for the informer again
+		l.cache.setErr(l.resource, ErrNotSynced)
+		return ErrNotSynced
+	}
+	return err
+}
+
+// list returns the cached resources of the namespace sorted by namespace and name
+func (l *Lister) list(namespace string) (*unstructured.UnstructuredList, error) {
+	informer := l.cache.informers[l.resource]
+
+	var err error
+	var objects []runtime.Object
+	if namespace == "" {
+		objects, err = informer.Lister().List(labels.Everything())
+	} else {
+		objects, err = informer.Lister().ByNamespace(namespace).List(labels.Everything())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{
+		"apiVersion": l.resource.GroupVersion().String(),
+		"kind":       "List",
+	}}
+	for _, object := range objects {
+		if item, ok := object.(*unstructured.Unstructured); ok {
+			// the cached objects are shared and must not be modified by the callers
+			list.Items = append(list.Items, *item.DeepCopy())
+		}
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		if list.Items[i].GetNamespace() != list.Items[j].GetNamespace() {
+			return list.Items[i].GetNamespace() < list.Items[j].GetNamespace()
+		}
+		return list.Items[i].GetName() < list.Items[j].GetName()
+	})
+	return list, nil
+}
+
+// watcher receives the changes of a resource in a namespace, an empty namespace receives the changes of all namespaces
+type watcher struct {
+	namespace string
+	handler   cache.ResourceEventHandler
+}
+
+// dispatcher returns the event handler of the informer of the resource which passes the changes on to its watchers
+func (c *Cache) dispatcher(resource schema.GroupVersionResource) cache.ResourceEventHandler {
+	dispatch := func(obj interface{}, notify func(handler cache.ResourceEventHandler)) {
+		object := obj
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			object = tombstone.Obj
+		}
+		accessor, err := meta.Accessor(object)
+		if err != nil {
+			return
+		}
+
+		c.watchMu.RLock()
+		defer c.watchMu.RUnlock()
+		for w := range c.watchers[resource] {
+			if w.namespace == "" || w.namespace == accessor.GetNamespace() {
+				notify(w.handler)
+			}
+		}
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			dispatch(obj, func(handler cache.ResourceEventHandler) { handler.OnAdd(obj) })
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			dispatch(newObj, func(handler cache.ResourceEventHandler) { handler.OnUpdate(oldObj, newObj) })
+		},
+		DeleteFunc: func(obj interface{}) {
+			dispatch(obj, func(handler cache.ResourceEventHandler) { handler.OnDelete(obj) })
+		},
+	}
+}
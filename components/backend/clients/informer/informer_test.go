@@ -0,0 +1,226 @@
+package informer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+var testResource = schema.GroupVersionResource{Group: "eventing.kyma-project.io", Version: "v1alpha1", Resource: "subscriptions"}
+
+func newSubscription(namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("eventing.kyma-project.io/v1alpha1")
+	obj.SetKind("Subscription")
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func TestListerList(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{testResource: "SubscriptionList"},
+		newSubscription("default", "b"),
+		newSubscription("default", "a"),
+		newSubscription("other", "c"),
+	)
+	cache := New(client, testResource)
+	defer cache.Close()
+
+	if cache.HasSynced() {
+		t.Fatal("expected the cache not to be synced before the first list")
+	}
+
+	list, err := cache.Lister(testResource).List("default")
+	if err != nil {
+		t.Fatalf("failed to list: %v", err)
+	}
+	if len(list.Items) != 2 || list.Items[0].GetName() != "a" || list.Items[1].GetName() != "b" {
+		t.Fatalf("expected the sorted subscriptions of the namespace, got: %v", list.Items)
+	}
+	if !cache.HasSynced() || !cache.Synced()[testResource] {
+		t.Fatal("expected the cache to be synced after the first list")
+	}
+
+	// the listed objects are copies of the cached objects
+	list.Items[0].SetName("modified")
+
+	all, err := cache.Lister(testResource).List("")
+	if err != nil {
+		t.Fatalf("failed to list: %v", err)
+	}
+	if len(all.Items) != 3 || all.Items[0].GetName() != "a" || all.Items[2].GetNamespace() != "other" {
+		t.Fatalf("expected the subscriptions of all namespaces, got: %v", all.Items)
+	}
+
+	// the informer picks up the changes made with the API server,
+	// the fake client does not send the changes made before the informer watches
+	deadline := time.Now().Add(5 * time.Second)
+	for !watching(client) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the informer to watch")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if _, err := client.Resource(testResource).Namespace("default").Create(context.Background(),
+		newSubscription("default", "d"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		list, err = cache.Lister(testResource).List("default")
+		if err == nil && len(list.Items) == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the created subscription to be cached, got: %v, %v", list, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func watching(client *dynamicfake.FakeDynamicClient) bool {
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "watch" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestListerForbidden(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{testResource: "SubscriptionList"})
+	// a namespace-scoped kubeconfig must not list the subscriptions of all namespaces
+	client.PrependReactor("list", "subscriptions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(testResource.GroupResource(), "", errors.New("cluster-wide list is not allowed"))
+	})
+	cache := New(client, testResource)
+	defer cache.Close()
+
+	start := time.Now()
+	if _, err := cache.Lister(testResource).List("default"); err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Fatalf("expected the Forbidden error of the informer, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= SyncTimeout {
+		t.Fatalf("expected the list to fail without waiting for the sync timeout, took %s", elapsed)
+	}
+
+	// the next lists fail right away
+	start = time.Now()
+	if _, err := cache.Lister(testResource).List("default"); err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Fatalf("expected the remembered Forbidden error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > syncPollInterval {
+		t.Fatalf("expected the list to fail right away, took %s", elapsed)
+	}
+}
+
+func TestListerClosed(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{testResource: "SubscriptionList"})
+	cache := New(client, testResource)
+	cache.Close()
+	cache.Close()
+
+	if _, err := cache.Lister(testResource).List(""); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got: %v", err)
+	}
+
+	var nilCache *Cache
+	nilCache.Close()
+}
+
+func TestListerWatch(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{testResource: "SubscriptionList"},
+		newSubscription("default", "a"),
+		newSubscription("other", "b"),
+	)
+	c := New(client, testResource)
+	defer c.Close()
+
+	watch := func(namespace string) (*unstructured.UnstructuredList, chan string, func()) {
+		added := make(chan string, 10)
+		list, stop, err := c.Lister(testResource).Watch(namespace, cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				added <- obj.(*unstructured.Unstructured).GetName()
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to watch: %v", err)
+		}
+		return list, added, stop
+	}
+	list, defaultAdded, stopDefault := watch("default")
+	if len(list.Items) != 1 || list.Items[0].GetName() != "a" {
+		t.Fatalf("expected the subscriptions of the namespace, got: %v", list.Items)
+	}
+	all, allAdded, stopAll := watch("")
+	defer stopAll()
+	if len(all.Items) != 2 {
+		t.Fatalf("expected the subscriptions of all namespaces, got: %v", all.Items)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !watching(client) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the informer to watch")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	create := func(namespace, name string) {
+		if _, err := client.Resource(testResource).Namespace(namespace).Create(context.Background(),
+			newSubscription(namespace, name), metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(added chan string, name string) {
+		t.Helper()
+		select {
+		case got := <-added:
+			if got != name {
+				t.Fatalf("expected %s to be added, got %s", name, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s to be added", name)
+		}
+	}
+
+	// the watches share the informer and get the changes of their namespace
+	create("other", "c")
+	expect(allAdded, "c")
+	create("default", "d")
+	expect(allAdded, "d")
+	expect(defaultAdded, "d")
+
+	// a stopped watch gets no changes anymore
+	stopDefault()
+	create("default", "e")
+	expect(allAdded, "e")
+	select {
+	case name := <-defaultAdded:
+		t.Fatalf("expected the stopped watch to get no changes, got %s", name)
+	default:
+	}
+
+	watches := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "watch" {
+			watches++
+		}
+	}
+	if watches != 1 {
+		t.Fatalf("expected the watches to share one informer, the API server was watched %d times", watches)
+	}
+}
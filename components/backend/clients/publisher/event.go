@@ -0,0 +1,213 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Mode is the format an event is published in
+type Mode string
+
+const (
+	// ModeBinary publishes the CloudEvent attributes as ce- headers and the data as body to /publish
+	ModeBinary Mode = "binary"
+	// ModeStructured publishes the CloudEvent as application/cloudevents+json to /publish
+	ModeStructured Mode = "structured"
+	// ModeLegacy publishes the event in the legacy Kyma format to /{app}/v1/events
+	ModeLegacy Mode = "legacy"
+)
+
+const (
+	// SpecVersion is the CloudEvents version of the published events
+	SpecVersion = "1.0"
+
+	defaultDataContentType = "application/json"
+)
+
+var (
+	// ErrInvalidEvent is returned for an event which can not be published
+	ErrInvalidEvent = errors.New("invalid event")
+
+	// extensionName is the format of the names of the CloudEvent attributes
+	extensionName = regexp.MustCompile(`^[a-z0-9]{1,20}$`)
+	// legacyType splits the type of a legacy event into the event type and its version, e.g. order.created.v1
+	legacyType = regexp.MustCompile(`^(.+)\.(v[0-9]+)$`)
+
+	// reservedAttributes are the CloudEvent attributes which can not be used as extension
+	reservedAttributes = map[string]bool{
+		"id": true, "source": true, "specversion": true, "type": true, "datacontenttype": true,
+		"dataschema": true, "subject": true, "time": true, "data": true, "data_base64": true,
+	}
+)
+
+// Event is a CloudEvent to publish, ID and Time are filled in if they are missing
+type Event struct {
+	ID              string            `json:"id,omitempty"`
+	Source          string            `json:"source"`
+	Type            string            `json:"type"`
+	Subject         string            `json:"subject,omitempty"`
+	Time            *time.Time        `json:"time,omitempty"`
+	DataContentType string            `json:"datacontenttype,omitempty"` // DataContentType defaults to application/json
+	DataSchema      string            `json:"dataschema,omitempty"`
+	Data            json.RawMessage   `json:"data,omitempty"`
+	Extensions      map[string]string `json:"extensions,omitempty"`
+}
+
+// Default fills in the missing id, time and data content type
+func (e *Event) Default() {
+	if e.ID == "" {
+		e.ID = uuid.NewString()
+	}
+	if e.Time == nil {
+		now := time.Now().UTC()
+		e.Time = &now
+	}
+	if e.DataContentType == "" {
+		e.DataContentType = defaultDataContentType
+	}
+}
+
+// Validate returns ErrInvalidEvent if the event can not be published in the mode
+func (e Event) Validate(mode Mode, app string) error {
+	switch {
+	case e.Type == "":
+		return fmt.Errorf("%w: type is missing", ErrInvalidEvent)
+	case e.Source == "" && mode != ModeLegacy:
+		return fmt.Errorf("%w: source is missing", ErrInvalidEvent)
+	case len(e.Data) > 0 && !json.Valid(e.Data):
+		return fmt.Errorf("%w: data is not valid JSON", ErrInvalidEvent)
+	}
+
+	for name := range e.Extensions {
+		if !extensionName.MatchString(name) || reservedAttributes[name] {
+			return fmt.Errorf("%w: extension %q must consist of up to 20 lower-case letters or digits "+
+				"and must not be a CloudEvent attribute", ErrInvalidEvent, name)
+		}
+	}
+
+	switch mode {
+	case ModeBinary, ModeStructured:
+	case ModeLegacy:
+		if app == "" {
+			return fmt.Errorf("%w: the legacy format requires the application", ErrInvalidEvent)
+		}
+		if !legacyType.MatchString(e.Type) {
+			return fmt.Errorf("%w: the type of a legacy event must end with its version, e.g. order.created.v1", ErrInvalidEvent)
+		}
+	default:
+		return fmt.Errorf("%w: unknown mode %q, expected binary, structured or legacy", ErrInvalidEvent, mode)
+	}
+	return nil
+}
+
+// NewRequest returns the request which publishes the event in the mode to the publisher proxy at the base URL.
+// The event is defaulted and validated, app is the application of a legacy event.
+func NewRequest(ctx context.Context, baseURL string, event *Event, mode Mode, app string) (*http.Request, error) {
+	event.Default()
+	if err := event.Validate(mode, app); err != nil {
+		return nil, err
+	}
+
+	switch mode {
+	case ModeStructured:
+		body, err := structuredBody(*event)
+		if err != nil {
+			return nil, err
+		}
+		return newPostRequest(ctx, baseURL+"/publish", "application/cloudevents+json", body)
+	case ModeLegacy:
+		body, err := legacyBody(*event)
+		if err != nil {
+			return nil, err
+		}
+		return newPostRequest(ctx, baseURL+"/"+url.PathEscape(app)+"/v1/events", "application/json", body)
+	default:
+		req, err := newPostRequest(ctx, baseURL+"/publish", event.DataContentType, binaryBody(*event))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("ce-specversion", SpecVersion)
+		req.Header.Set("ce-id", event.ID)
+		req.Header.Set("ce-source", event.Source)
+		req.Header.Set("ce-type", event.Type)
+		req.Header.Set("ce-time", event.Time.Format(time.RFC3339Nano))
+		if event.Subject != "" {
+			req.Header.Set("ce-subject", event.Subject)
+		}
+		if event.DataSchema != "" {
+			req.Header.Set("ce-dataschema", event.DataSchema)
+		}
+		for name, value := range event.Extensions {
+			req.Header.Set("ce-"+name, value)
+		}
+		return req, nil
+	}
+}
+
+func newPostRequest(ctx context.Context, target, contentType string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return req, nil
+}
+
+// binaryBody returns the data as body, a JSON string is sent as its text unless the data is JSON
+func binaryBody(event Event) []byte {
+	var text string
+	if !strings.Contains(event.DataContentType, "json") && json.Unmarshal(event.Data, &text) == nil {
+		return []byte(text)
+	}
+	return event.Data
+}
+
+// structuredBody returns the event in the JSON format of CloudEvents, the extensions are top-level attributes
+func structuredBody(event Event) ([]byte, error) {
+	attributes := map[string]interface{}{
+		"specversion":     SpecVersion,
+		"id":              event.ID,
+		"source":          event.Source,
+		"type":            event.Type,
+		"time":            event.Time.Format(time.RFC3339Nano),
+		"datacontenttype": event.DataContentType,
+	}
+	if event.Subject != "" {
+		attributes["subject"] = event.Subject
+	}
+	if event.DataSchema != "" {
+		attributes["dataschema"] = event.DataSchema
+	}
+	if len(event.Data) > 0 {
+		attributes["data"] = event.Data
+	}
+	for name, value := range event.Extensions {
+		attributes[name] = value
+	}
+	return json.Marshal(attributes)
+}
+
+// legacyBody returns the event in the legacy Kyma format, the version is split from the type
+func legacyBody(event Event) ([]byte, error) {
+	parts := legacyType.FindStringSubmatch(event.Type)
+	body := map[string]interface{}{
+		"event-type":         parts[1],
+		"event-type-version": parts[2],
+		"event-id":           event.ID,
+		"event-time":         event.Time.Format(time.RFC3339Nano),
+	}
+	if len(event.Data) > 0 {
+		body["data"] = event.Data
+	}
+	return json.Marshal(body)
+}
@@ -0,0 +1,104 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestNewRequestBinary(t *testing.T) {
+	event := &Event{
+		Source:     "shop",
+		Type:       "sap.kyma.custom.shop.order.created.v1",
+		Data:       json.RawMessage(`{"orderId":42}`),
+		Extensions: map[string]string{"traceparent": "00-abc-def-01"},
+	}
+	req, err := NewRequest(context.Background(), "http://epp", event, ModeBinary, "")
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	if req.URL.String() != "http://epp/publish" || req.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected request: %s %v", req.URL, req.Header)
+	}
+	if event.ID == "" || req.Header.Get("ce-id") != event.ID || req.Header.Get("ce-time") == "" {
+		t.Fatalf("expected the id and time to be filled in, got: %v", req.Header)
+	}
+	if req.Header.Get("ce-traceparent") != "00-abc-def-01" || req.Header.Get("ce-specversion") != "1.0" {
+		t.Fatalf("unexpected CloudEvent headers: %v", req.Header)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"orderId":42}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+}
+
+func TestNewRequestStructured(t *testing.T) {
+	eventTime := time.Date(2022, 7, 20, 12, 0, 0, 0, time.UTC)
+	event := &Event{ID: "A234-1234", Source: "shop", Type: "order.created.v1", Time: &eventTime,
+		Data: json.RawMessage(`"hello"`), DataContentType: "text/plain", Extensions: map[string]string{"tenant": "t1"}}
+	req, err := NewRequest(context.Background(), "http://epp", event, ModeStructured, "")
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	if req.Header.Get("Content-Type") != "application/cloudevents+json" {
+		t.Fatalf("unexpected content type: %s", req.Header.Get("Content-Type"))
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	for attribute, want := range map[string]interface{}{
+		"id": "A234-1234", "specversion": "1.0", "time": "2022-07-20T12:00:00Z", "tenant": "t1", "data": "hello",
+	} {
+		if body[attribute] != want {
+			t.Fatalf("expected %s %v, got: %v", attribute, want, body)
+		}
+	}
+}
+
+func TestNewRequestLegacy(t *testing.T) {
+	event := &Event{Type: "order.created.v1", Data: json.RawMessage(`{"orderId":42}`)}
+	req, err := NewRequest(context.Background(), "http://epp", event, ModeLegacy, "shop")
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	if req.URL.String() != "http://epp/shop/v1/events" {
+		t.Fatalf("unexpected url: %s", req.URL)
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["event-type"] != "order.created" || body["event-type-version"] != "v1" || body["event-id"] != event.ID {
+		t.Fatalf("unexpected legacy event: %v", body)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		mode  Mode
+		app   string
+	}{
+		{name: "missing type", event: Event{Source: "shop"}, mode: ModeBinary},
+		{name: "missing source", event: Event{Type: "order.created.v1"}, mode: ModeStructured},
+		{name: "invalid data", event: Event{Source: "shop", Type: "order.created.v1", Data: json.RawMessage(`{`)}, mode: ModeBinary},
+		{name: "reserved extension", event: Event{Source: "shop", Type: "order.created.v1", Extensions: map[string]string{"time": "now"}}, mode: ModeBinary},
+		{name: "invalid extension", event: Event{Source: "shop", Type: "order.created.v1", Extensions: map[string]string{"Trace-Id": "1"}}, mode: ModeBinary},
+		{name: "legacy without app", event: Event{Type: "order.created.v1"}, mode: ModeLegacy},
+		{name: "legacy without version", event: Event{Type: "order.created"}, mode: ModeLegacy, app: "shop"},
+		{name: "unknown mode", event: Event{Source: "shop", Type: "order.created.v1"}, mode: "batch"},
+	}
+
+	for _, tc := range tests {
+		if err := tc.event.Validate(tc.mode, tc.app); !errors.Is(err, ErrInvalidEvent) {
+			t.Fatalf("%s: expected ErrInvalidEvent, got: %v", tc.name, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"time"
 
 	"k8s.io/client-go/util/retry"
 
@@ -18,20 +19,37 @@ import (
 // Client struct for Kyma Subscription client
 type Client struct {
 	client dynamic.Interface
+	lister Lister
+}
+
+// Lister lists the kyma subscriptions from a cache, an empty namespace lists all namespaces
+type Lister interface {
+	List(namespace string) (*unstructured.UnstructuredList, error)
 }
 
 // NewClient creates and returns new client for Kyma Subscriptions
 func NewClient(client dynamic.Interface) Client {
-	return Client{client}
+	return Client{client: client}
+}
+
+// WithLister returns a copy of the client which lists the kyma subscriptions from the cache of the lister.
+// The API server is listed if the cache fails.
+func (c Client) WithLister(lister Lister) Client {
+	c.lister = lister
+	return c
+}
+
+// Fresh returns a copy of the client which bypasses the cache and lists from the API server
+func (c Client) Fresh() Client {
+	c.lister = nil
+	return c
 }
 
 // List returns the list of kyma subscriptions in specified namespace
 // or returns an error if it fails for any reason
 func (c Client) List(namespace string) (*eventingv1alpha1.SubscriptionList, error) {
 
-	subscriptionsUnstructured, err := c.client.Resource(GroupVersionResource()).Namespace(namespace).List(
-		context.Background(), metav1.ListOptions{})
-
+	subscriptionsUnstructured, err := c.ListJson(namespace)
 	if err != nil {
 		return nil, err
 	}
@@ -42,6 +60,15 @@ func (c Client) List(namespace string) (*eventingv1alpha1.SubscriptionList, erro
 // or returns an error if it fails for any reason
 func (c Client) ListJson(namespace string) (*unstructured.UnstructuredList, error) {
 
+	if c.lister != nil {
+		subscriptionsUnstructured, err := c.lister.List(namespace)
+		if err == nil {
+			subscriptionsUnstructured.SetKind("SubscriptionList")
+			return subscriptionsUnstructured, nil
+		}
+		log.Printf("failed to list subscriptions from the cache, falling back to the API server: %v", err)
+	}
+
 	subscriptionsUnstructured, err := c.client.Resource(GroupVersionResource()).Namespace(namespace).List(
 		context.Background(), metav1.ListOptions{})
 
@@ -64,17 +91,45 @@ func (c Client) GetSubJson(name, namespace string) (*unstructured.Unstructured,
 	return subscriptionUnstructured, nil
 }
 
-//// GetSub returns the kyma subscription in specified namespace
-//// or returns an error if it fails for any reason
-//func (c Client) GetSub(name, namespace string) (*eventingv1alpha1.Subscription, error) {
-//
-//	subscriptionUnstructured, err := c.GetSubJson(name, namespace)
-//	if err != nil {
-//		return nil, err
-//	}
-//
-//	return subscriptionUnstructured, nil
-//}
+// GetSub returns the kyma subscription in specified namespace
+// or returns an error if it fails for any reason
+func (c Client) GetSub(name, namespace string) (*eventingv1alpha1.Subscription, error) {
+
+	subscriptionUnstructured, err := c.GetSubJson(name, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := new(eventingv1alpha1.Subscription)
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(subscriptionUnstructured.Object, sub)
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// WaitUntilReady polls the kyma subscription until it is ready or the context is done.
+// The last fetched subscription is returned with the context error if it did not become ready.
+func (c Client) WaitUntilReady(ctx context.Context, name, namespace string, interval time.Duration) (*eventingv1alpha1.Subscription, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sub, err := c.GetSub(name, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if sub.Status.Ready {
+			return sub, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return sub, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
 
 // CreateSubscription creates a new kyma subscriptions in specified namespace
 // or returns an error if it fails for any reason
@@ -111,19 +166,26 @@ func (c Client) UpdateSubscription(sub eventingv1alpha1.Subscription) (*unstruct
 		result, getErr := c.GetSubJson(sub.Name, sub.Namespace)
 		if getErr != nil {
 			log.Printf("failed to get latest version of subscription: %v", getErr)
-			return err
+			return getErr
 		}
 
 		if err := unstructured.SetNestedField(result.Object, mapInterfaceSub["spec"], "spec"); err != nil {
 			return err
 		}
+		// labels and annotations are only replaced if they are given
+		if sub.Labels != nil {
+			result.SetLabels(sub.Labels)
+		}
+		if sub.Annotations != nil {
+			result.SetAnnotations(sub.Annotations)
+		}
 
 		_, updateErr := c.client.Resource(GroupVersionResource()).Namespace(sub.Namespace).Update(context.Background(), result, metav1.UpdateOptions{})
 		return updateErr
 	})
 
 	if retryErr != nil {
-		return nil, err
+		return nil, retryErr
 	}
 
 	return c.GetSubJson(sub.Name, sub.Namespace)
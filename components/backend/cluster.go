@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/auth"
+)
+
+// clusterHeader is the request header to select the target cluster,
+// the /api/clusters/{cluster} route prefix takes precedence over it.
+const clusterHeader = "X-Cluster"
+
+type clusterContextKey struct{}
+
+// clusterMiddleware resolves the cluster of the request and stores it in the request context.
+// It responds with 404 if the cluster is not registered by the authenticated user.
+func clusterMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := clusterName(r)
+
+		cluster, ok := clusters.Get(auth.UserFromContext(r.Context()), name)
+		if !ok {
+			log.Printf("%s %s unknown cluster: %s", r.Method, r.RequestURI, name)
+			http.Error(w, fmt.Sprintf("%v: %q", ErrClusterNotFound, name), http.StatusNotFound)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clusterContextKey{}, cluster)))
+	})
+}
+
+// clusterName returns the name of the cluster selected by the request,
+// an empty name selects the default cluster
+func clusterName(r *http.Request) string {
+	if name, ok := mux.Vars(r)["cluster"]; ok {
+		return name
+	}
+	return r.Header.Get(clusterHeader)
+}
+
+// clusterFromContext returns the cluster resolved by clusterMiddleware
+func clusterFromContext(ctx context.Context) *Cluster {
+	return ctx.Value(clusterContextKey{}).(*Cluster)
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	serverlessv1alpha1 "github.com/kyma-project/kyma/components/function-controller/pkg/apis/serverless/v1alpha1"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/function"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// diagnosticsBuildLogLines is the number of lines of the build logs in the diagnostics
+	diagnosticsBuildLogLines = int64(100)
+	// diagnosticsMaxEvents is the number of the most recent events in the diagnostics
+	diagnosticsMaxEvents = 50
+)
+
+// functionConditionTypes are the conditions of a function in the order the serverless controller sets them
+var functionConditionTypes = []serverlessv1alpha1.ConditionType{
+	serverlessv1alpha1.ConditionConfigurationReady,
+	serverlessv1alpha1.ConditionBuildReady,
+	serverlessv1alpha1.ConditionRunning,
+}
+
+// problemReasons are the waiting and terminated reasons of a container which are reported as problems
+var problemReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+	"OOMKilled":                  true,
+	"Error":                      true,
+}
+
+// FunctionDiagnostics is the report of the build and the runtime of a function
+type FunctionDiagnostics struct {
+	Name       string                 `json:"name"`
+	Namespace  string                 `json:"namespace"`
+	Ready      bool                   `json:"ready"` // Ready is true if all conditions of the function are true
+	Conditions []ConditionSummary     `json:"conditions"`
+	Build      *BuildDiagnostics      `json:"build,omitempty"` // Build is the most recent build job
+	Deployment *DeploymentDiagnostics `json:"deployment,omitempty"`
+	Pods       []PodDiagnostics       `json:"pods"`
+	Events     []EventDiagnostics     `json:"events"`
+	Problems   []string               `json:"problems"`         // Problems summarizes what is wrong, most important first
+	Errors     []string               `json:"errors,omitempty"` // Errors are the parts of the report which could not be collected
+}
+
+// BuildDiagnostics is the state of a build job of the function
+type BuildDiagnostics struct {
+	Job            string             `json:"job"`
+	Active         int32              `json:"active"`
+	Succeeded      int32              `json:"succeeded"`
+	Failed         int32              `json:"failed"`
+	StartTime      *metav1.Time       `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time       `json:"completionTime,omitempty"`
+	Conditions     []ConditionSummary `json:"conditions"`
+	Pod            string             `json:"pod,omitempty"`  // Pod is the most recent pod of the job
+	Logs           string             `json:"logs,omitempty"` // Logs are the last lines of the logs of the pod
+}
+
+// DeploymentDiagnostics is the state of the deployment of the function
+type DeploymentDiagnostics struct {
+	Name              string             `json:"name"`
+	Replicas          int32              `json:"replicas"`
+	ReadyReplicas     int32              `json:"readyReplicas"`
+	AvailableReplicas int32              `json:"availableReplicas"`
+	UpdatedReplicas   int32              `json:"updatedReplicas"`
+	Conditions        []ConditionSummary `json:"conditions"`
+}
+
+// PodDiagnostics is the state of a pod of the function
+type PodDiagnostics struct {
+	Name       string                 `json:"name"`
+	Phase      corev1.PodPhase        `json:"phase"`
+	Ready      bool                   `json:"ready"`
+	Containers []ContainerDiagnostics `json:"containers"`
+}
+
+// ContainerDiagnostics is the state of a container of a function pod
+type ContainerDiagnostics struct {
+	Name         string `json:"name"`
+	Ready        bool   `json:"ready"`
+	RestartCount int32  `json:"restartCount"`
+	State        string `json:"state"`            // State is waiting, running or terminated
+	Reason       string `json:"reason,omitempty"` // Reason is e.g. CrashLoopBackOff or ImagePullBackOff
+	Message      string `json:"message,omitempty"`
+	ExitCode     *int32 `json:"exitCode,omitempty"`
+}
+
+// EventDiagnostics is a Kubernetes event of the function or its resources
+type EventDiagnostics struct {
+	Kind          string      `json:"kind"`
+	Name          string      `json:"name"`
+	Type          string      `json:"type"` // Type is Normal or Warning
+	Reason        string      `json:"reason"`
+	Message       string      `json:"message"`
+	Count         int32       `json:"count"`
+	LastTimestamp metav1.Time `json:"lastTimestamp"`
+}
+
+// diagnoseFunction collects the diagnostics of the function, a part which can not be collected is reported in Errors
+func diagnoseFunction(ctx context.Context, cluster *Cluster, fn *serverlessv1alpha1.Function) *FunctionDiagnostics {
+	clientset := cluster.Clientset
+	report := &FunctionDiagnostics{
+		Name:       fn.Name,
+		Namespace:  fn.Namespace,
+		Ready:      true,
+		Conditions: []ConditionSummary{},
+		Pods:       []PodDiagnostics{},
+		Events:     []EventDiagnostics{},
+		Problems:   []string{},
+	}
+	addError := func(part string, err error) {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", part, err))
+	}
+
+	// the objects the events are collected for by kind and name
+	involved := map[string]bool{"Function/" + fn.Name: true}
+
+	for _, conditionType := range functionConditionTypes {
+		condition := functionCondition(fn, conditionType)
+		report.Conditions = append(report.Conditions, condition)
+		if condition.Status != string(corev1.ConditionTrue) {
+			report.Ready = false
+			if condition.Status == string(corev1.ConditionFalse) {
+				report.Problems = append(report.Problems, fmt.Sprintf("%s: %s %s", condition.Type, condition.Reason, condition.Message))
+			}
+		}
+	}
+
+	functionSelector := labels.Set{serverlessv1alpha1.FunctionNameLabel: fn.Name}.String()
+
+	jobs, err := clientset.BatchV1().Jobs(fn.Namespace).List(ctx, metav1.ListOptions{LabelSelector: functionSelector})
+	if err != nil {
+		addError("build jobs", err)
+	} else if job := latestJob(jobs.Items); job != nil {
+		involved["Job/"+job.Name] = true
+		report.Build = diagnoseBuild(ctx, cluster, job, involved, addError)
+		if job.Status.Failed > 0 {
+			report.Problems = append(report.Problems, fmt.Sprintf("build job %s failed, see the build logs", job.Name))
+		}
+	}
+
+	deployments, err := clientset.AppsV1().Deployments(fn.Namespace).List(ctx, metav1.ListOptions{LabelSelector: functionSelector})
+	if err != nil {
+		addError("deployment", err)
+	} else if len(deployments.Items) > 0 {
+		deployment := deployments.Items[0]
+		involved["Deployment/"+deployment.Name] = true
+		report.Deployment = diagnoseDeployment(deployment)
+	}
+
+	pods, err := clientset.CoreV1().Pods(fn.Namespace).List(ctx, metav1.ListOptions{LabelSelector: function.PodSelector(fn.Name)})
+	if err != nil {
+		addError("pods", err)
+	} else {
+		sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+		for _, pod := range pods.Items {
+			involved["Pod/"+pod.Name] = true
+			podDiagnostics := diagnosePod(pod)
+			report.Pods = append(report.Pods, podDiagnostics)
+			report.Problems = append(report.Problems, podProblems(podDiagnostics)...)
+		}
+	}
+
+	events, err := involvedEvents(ctx, clientset, fn.Namespace, involved)
+	if err != nil {
+		addError("events", err)
+	} else {
+		report.Events = recentEvents(events, involved)
+	}
+
+	return report
+}
+
+// functionCondition returns the condition of the function, it is unknown if the controller did not set it yet
+func functionCondition(fn *serverlessv1alpha1.Function, conditionType serverlessv1alpha1.ConditionType) ConditionSummary {
+	for _, condition := range fn.Status.Conditions {
+		if condition.Type == conditionType {
+			return ConditionSummary{
+				Type:               string(condition.Type),
+				Status:             string(condition.Status),
+				Reason:             string(condition.Reason),
+				Message:            condition.Message,
+				LastTransitionTime: condition.LastTransitionTime,
+			}
+		}
+	}
+	return ConditionSummary{Type: string(conditionType), Status: string(corev1.ConditionUnknown)}
+}
+
+// latestJob returns the most recently created job or nil if there is none
+func latestJob(jobs []batchv1.Job) *batchv1.Job {
+	var latest *batchv1.Job
+	for i := range jobs {
+		if latest == nil || latest.CreationTimestamp.Before(&jobs[i].CreationTimestamp) {
+			latest = &jobs[i]
+		}
+	}
+	return latest
+}
+
+func diagnoseBuild(ctx context.Context, cluster *Cluster, job *batchv1.Job, involved map[string]bool,
+	addError func(string, error)) *BuildDiagnostics {
+	build := &BuildDiagnostics{
+		Job:            job.Name,
+		Active:         job.Status.Active,
+		Succeeded:      job.Status.Succeeded,
+		Failed:         job.Status.Failed,
+		StartTime:      job.Status.StartTime,
+		CompletionTime: job.Status.CompletionTime,
+		Conditions:     []ConditionSummary{},
+	}
+	for _, condition := range job.Status.Conditions {
+		build.Conditions = append(build.Conditions, ConditionSummary{
+			Type:               string(condition.Type),
+			Status:             string(condition.Status),
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+			LastTransitionTime: condition.LastTransitionTime,
+		})
+	}
+
+	// the pods of a job are labeled with the job name by the job controller
+	pods, err := cluster.Clientset.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.Set{"job-name": job.Name}.String(),
+	})
+	if err != nil {
+		addError("build pods", err)
+		return build
+	}
+	var pod *corev1.Pod
+	for i := range pods.Items {
+		if pod == nil || pod.CreationTimestamp.Before(&pods.Items[i].CreationTimestamp) {
+			pod = &pods.Items[i]
+		}
+	}
+	if pod == nil || len(pod.Spec.Containers) == 0 {
+		return build
+	}
+	involved["Pod/"+pod.Name] = true
+	build.Pod = pod.Name
+
+	tailLines := diagnosticsBuildLogLines
+	logs, err := cluster.FunctionClient.GetPodLogs(ctx, cluster.Clientset, pod.Name, pod.Namespace, function.LogOptions{
+		Container: pod.Spec.Containers[0].Name,
+		TailLines: &tailLines,
+	})
+	if err != nil {
+		addError("build logs", err)
+		return build
+	}
+	build.Logs = logs
+	return build
+}
+
+func diagnoseDeployment(deployment appsv1.Deployment) *DeploymentDiagnostics {
+	diagnostics := &DeploymentDiagnostics{
+		Name:              deployment.Name,
+		Replicas:          deployment.Status.Replicas,
+		ReadyReplicas:     deployment.Status.ReadyReplicas,
+		AvailableReplicas: deployment.Status.AvailableReplicas,
+		UpdatedReplicas:   deployment.Status.UpdatedReplicas,
+		Conditions:        []ConditionSummary{},
+	}
+	for _, condition := range deployment.Status.Conditions {
+		diagnostics.Conditions = append(diagnostics.Conditions, ConditionSummary{
+			Type:               string(condition.Type),
+			Status:             string(condition.Status),
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+			LastTransitionTime: condition.LastTransitionTime,
+		})
+	}
+	return diagnostics
+}
+
+func diagnosePod(pod corev1.Pod) PodDiagnostics {
+	diagnostics := PodDiagnostics{
+		Name:       pod.Name,
+		Phase:      pod.Status.Phase,
+		Containers: []ContainerDiagnostics{},
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			diagnostics.Ready = condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		container := ContainerDiagnostics{
+			Name:         status.Name,
+			Ready:        status.Ready,
+			RestartCount: status.RestartCount,
+		}
+		switch {
+		case status.State.Waiting != nil:
+			container.State = "waiting"
+			container.Reason = status.State.Waiting.Reason
+			container.Message = status.State.Waiting.Message
+		case status.State.Terminated != nil:
+			container.State = "terminated"
+			container.Reason = status.State.Terminated.Reason
+			container.Message = status.State.Terminated.Message
+			container.ExitCode = &status.State.Terminated.ExitCode
+		case status.State.Running != nil:
+			container.State = "running"
+		}
+		// a running container which crashed before is reported with the reason of its last termination
+		if container.State != "terminated" && status.LastTerminationState.Terminated != nil && container.Reason == "" {
+			container.Reason = status.LastTerminationState.Terminated.Reason
+			container.ExitCode = &status.LastTerminationState.Terminated.ExitCode
+		}
+		diagnostics.Containers = append(diagnostics.Containers, container)
+	}
+	return diagnostics
+}
+
+// podProblems returns the problems of the containers of the pod, e.g. a crash loop or an image which can not be pulled
+func podProblems(pod PodDiagnostics) []string {
+	var problems []string
+	for _, container := range pod.Containers {
+		if !problemReasons[container.Reason] {
+			continue
+		}
+		problem := fmt.Sprintf("pod %s container %s: %s", pod.Name, container.Name, container.Reason)
+		if container.Message != "" {
+			problem += ": " + strings.TrimSpace(container.Message)
+		}
+		problems = append(problems, problem)
+	}
+	if pod.Phase == corev1.PodPending && len(problems) == 0 {
+		problems = append(problems, fmt.Sprintf("pod %s is pending", pod.Name))
+	}
+	return problems
+}
+
+// involvedEvents lists the events of the involved objects, one query per object,
+// so that the diagnostics do not read all events of a busy namespace
+func involvedEvents(ctx context.Context, clientset kubernetes.Interface, namespace string, involved map[string]bool) ([]corev1.Event, error) {
+	var events []corev1.Event
+	for object := range involved {
+		kind, name, _ := strings.Cut(object, "/")
+		list, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+			FieldSelector: fields.Set{"involvedObject.kind": kind, "involvedObject.name": name}.String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, list.Items...)
+	}
+	return events, nil
+}
+
+// recentEvents returns the most recent events of the involved objects, newest first
+func recentEvents(events []corev1.Event, involved map[string]bool) []EventDiagnostics {
+	recent := []EventDiagnostics{}
+	for _, event := range events {
+		if !involved[event.InvolvedObject.Kind+"/"+event.InvolvedObject.Name] {
+			continue
+		}
+		lastTimestamp := event.LastTimestamp
+		if lastTimestamp.IsZero() {
+			lastTimestamp = metav1.NewTime(event.EventTime.Time)
+		}
+		recent = append(recent, EventDiagnostics{
+			Kind:          event.InvolvedObject.Kind,
+			Name:          event.InvolvedObject.Name,
+			Type:          event.Type,
+			Reason:        event.Reason,
+			Message:       event.Message,
+			Count:         event.Count,
+			LastTimestamp: lastTimestamp,
+		})
+	}
+
+	sort.SliceStable(recent, func(i, j int) bool {
+		return recent[j].LastTimestamp.Before(&recent[i].LastTimestamp)
+	})
+	if len(recent) > diagnosticsMaxEvents {
+		recent = recent[:diagnosticsMaxEvents]
+	}
+	return recent
+}
+
+func getFunctionDiagnostics(w http.ResponseWriter, r *http.Request) {
+	// Fetch data from URI
+	namespace := mux.Vars(r)["ns"]
+	name := mux.Vars(r)["name"]
+
+	cluster := clusterFromContext(r.Context())
+	fn, err := cluster.FunctionClient.GetFn(name, namespace)
+	if apierrors.IsNotFound(err) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report := diagnoseFunction(r.Context(), cluster, fn)
+
+	// Convert response to bytes
+	data, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response to user
+	_, err = w.Write(data)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
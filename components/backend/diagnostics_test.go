@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	serverlessv1alpha1 "github.com/kyma-project/kyma/components/function-controller/pkg/apis/serverless/v1alpha1"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/function"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestGetFunctionDiagnostics(t *testing.T) {
+	fn := newTestFunction()
+	fn.Name = "orders"
+	fn.Status.Conditions = []serverlessv1alpha1.Condition{
+		{Type: serverlessv1alpha1.ConditionConfigurationReady, Status: corev1.ConditionTrue},
+		{Type: serverlessv1alpha1.ConditionBuildReady, Status: corev1.ConditionTrue},
+		{Type: serverlessv1alpha1.ConditionRunning, Status: corev1.ConditionFalse,
+			Reason: serverlessv1alpha1.ConditionReasonMinReplicasNotAvailable, Message: "Minimum replicas not available"},
+	}
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeDynamicClient(&unstructured.Unstructured{Object: object})
+
+	functionLabels := map[string]string{serverlessv1alpha1.FunctionNameLabel: "orders"}
+	now := metav1.Now()
+	older := metav1.NewTime(now.Add(-time.Hour))
+
+	pod := newFunctionPod("orders-a", "orders")
+	pod.Status = corev1.PodStatus{
+		Phase: corev1.PodRunning,
+		ContainerStatuses: []corev1.ContainerStatus{{
+			Name:         "function",
+			RestartCount: 4,
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+				Reason: "CrashLoopBackOff", Message: "back-off 1m20s restarting failed container",
+			}},
+		}},
+	}
+	buildPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders-build-x", Namespace: "default", Labels: map[string]string{"job-name": "orders-build"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "executor"}}},
+	}
+
+	cluster := &Cluster{
+		FunctionClient: function.NewClient(client),
+		Clientset: fake.NewSimpleClientset(
+			&batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "orders-build-old", Namespace: "default", Labels: functionLabels, CreationTimestamp: older},
+				Status:     batchv1.JobStatus{Failed: 1},
+			},
+			&batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "orders-build", Namespace: "default", Labels: functionLabels, CreationTimestamp: now},
+				Status:     batchv1.JobStatus{Succeeded: 1},
+			},
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "orders-deploy", Namespace: "default", Labels: functionLabels},
+				Status:     appsv1.DeploymentStatus{Replicas: 1},
+			},
+			pod, buildPod,
+			&corev1.Event{
+				ObjectMeta:     metav1.ObjectMeta{Name: "orders-a.1", Namespace: "default"},
+				InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "orders-a"},
+				Type:           corev1.EventTypeWarning, Reason: "BackOff", LastTimestamp: now,
+			},
+			&corev1.Event{
+				ObjectMeta:     metav1.ObjectMeta{Name: "other.1", Namespace: "default"},
+				InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "other"},
+				Type:           corev1.EventTypeWarning, Reason: "BackOff", LastTimestamp: now,
+			},
+		),
+	}
+
+	// the fake clientset ignores field selectors, the events are selected by their involved object like the API server does
+	clientset := cluster.Clientset.(*fake.Clientset)
+	clientset.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		selector := action.(k8stesting.ListAction).GetListRestrictions().Fields
+		if selector.Empty() {
+			t.Error("expected the events to be selected by their involved object")
+		}
+		objects, err := clientset.Tracker().List(corev1.SchemeGroupVersion.WithResource("events"), corev1.SchemeGroupVersion.WithKind("Event"), "default")
+		if err != nil {
+			return true, nil, err
+		}
+		events := &corev1.EventList{}
+		for _, event := range objects.(*corev1.EventList).Items {
+			if selector.Matches(fields.Set{"involvedObject.kind": event.InvolvedObject.Kind, "involvedObject.name": event.InvolvedObject.Name}) {
+				events.Items = append(events.Items, event)
+			}
+		}
+		return true, events, nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/default/funcs/orders/diagnostics", nil)
+	req = mux.SetURLVars(req, map[string]string{"ns": "default", "name": "orders"})
+	req = req.WithContext(context.WithValue(req.Context(), clusterContextKey{}, cluster))
+	rec := httptest.NewRecorder()
+	getFunctionDiagnostics(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var report FunctionDiagnostics
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Ready || len(report.Conditions) != 3 || report.Conditions[2].Reason != "MinReplicasNotAvailable" {
+		t.Fatalf("unexpected conditions: %+v", report.Conditions)
+	}
+	if report.Build == nil || report.Build.Job != "orders-build" || report.Build.Pod != "orders-build-x" || report.Build.Logs != "fake logs" {
+		t.Fatalf("expected the most recent build job with its logs, got: %+v", report.Build)
+	}
+	if report.Deployment == nil || report.Deployment.Name != "orders-deploy" {
+		t.Fatalf("unexpected deployment: %+v", report.Deployment)
+	}
+	if len(report.Pods) != 1 || report.Pods[0].Containers[0].Reason != "CrashLoopBackOff" {
+		t.Fatalf("unexpected pods: %+v", report.Pods)
+	}
+	if len(report.Problems) != 2 || !strings.Contains(report.Problems[1], "CrashLoopBackOff") {
+		t.Fatalf("expected the failing condition and the crash loop as problems, got: %v", report.Problems)
+	}
+	if len(report.Events) != 1 || report.Events[0].Name != "orders-a" {
+		t.Fatalf("expected only the events of the function resources, got: %+v", report.Events)
+	}
+	if len(report.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", report.Errors)
+	}
+
+	req = mux.SetURLVars(req, map[string]string{"ns": "default", "name": "missing"})
+	rec = httptest.NewRecorder()
+	getFunctionDiagnostics(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing function, got %d", rec.Code)
+	}
+}
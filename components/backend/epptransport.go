@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/forwarder"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+)
+
+// EPPTransportKind is how the eventing publisher proxy of a cluster is reached
+type EPPTransportKind string
+
+const (
+	// EPPTransportAuto reaches the publisher proxy by DNS inside the cluster, otherwise by a port-forward
+	EPPTransportAuto EPPTransportKind = "auto"
+	// EPPTransportDNS reaches the publisher proxy by the DNS name of its service, only inside the cluster
+	EPPTransportDNS EPPTransportKind = "dns"
+	// EPPTransportPortForward reaches the publisher proxy by a port-forward to one of its pods
+	EPPTransportPortForward EPPTransportKind = "port-forward"
+	// EPPTransportServiceProxy reaches the publisher proxy by the service proxy of the API server
+	EPPTransportServiceProxy EPPTransportKind = "service-proxy"
+)
+
+const (
+	// eppConnectTimeout limits how long opening the port-forward to the publisher proxy may take
+	eppConnectTimeout = 30 * time.Second
+	// eppTransportEnv selects the transport of the clusters without an override, default: auto
+	eppTransportEnv = "EPP_TRANSPORT"
+
+	eppNamespace   = "kyma-system"
+	eppServiceName = "eventing-publisher-proxy"
+	eppServicePort = 80
+	eppPodPort     = 8080
+)
+
+// eppServiceURL is the address of the publisher proxy inside the cluster.
+// It is a variable, so that the tests can replace the publisher proxy.
+var eppServiceURL = fmt.Sprintf("http://%s.%s.svc.cluster.local", eppServiceName, eppNamespace)
+
+// parseEPPTransportKind parses the transport, an empty transport is auto
+func parseEPPTransportKind(value string) (EPPTransportKind, error) {
+	switch kind := EPPTransportKind(value); kind {
+	case "":
+		return EPPTransportAuto, nil
+	case EPPTransportAuto, EPPTransportDNS, EPPTransportPortForward, EPPTransportServiceProxy:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("unknown EPP transport %q, expected auto, dns, port-forward or service-proxy", value)
+	}
+}
+
+// checkEPPTransportOverride rejects the dns transport for a cluster the backend does not run in,
+// its DNS name is the publisher proxy of the backend's own cluster which must not receive the events of other clusters
+func checkEPPTransportOverride(ctx context.Context, cluster *Cluster, kind EPPTransportKind) error {
+	if kind == EPPTransportDNS && !cluster.InCluster(ctx) {
+		return fmt.Errorf("EPP transport %s is only allowed for the cluster the backend runs in", kind)
+	}
+	return nil
+}
+
+// defaultEPPTransportKind returns the transport configured by the environment
+func defaultEPPTransportKind() EPPTransportKind {
+	kind, err := parseEPPTransportKind(os.Getenv(eppTransportEnv))
+	if err != nil {
+		log.Printf("%s: %v", eppTransportEnv, err)
+		return EPPTransportAuto
+	}
+	return kind
+}
+
+// eppRequestBuilder builds the request to the publisher proxy for its base URL
+type eppRequestBuilder func(ctx context.Context, baseURL string) (*http.Request, error)
+
+// eppTransport sends requests to the publisher proxy of a cluster
+type eppTransport interface {
+	Kind() EPPTransportKind
+	// Connect prepares the transport, e.g. opens the port-forward
+	Connect(ctx context.Context) error
+	// Send sends the request built for the base URL of the publisher proxy
+	Send(ctx context.Context, build eppRequestBuilder) (*http.Response, error)
+}
+
+// newEPPTransport returns the transport of the kind to the publisher proxy of the cluster,
+// auto is resolved by whether the backend runs inside the cluster
+func newEPPTransport(ctx context.Context, cluster *Cluster, kind EPPTransportKind) eppTransport {
+	if kind == EPPTransportAuto {
+		kind = EPPTransportPortForward
+		if cluster.InCluster(ctx) {
+			kind = EPPTransportDNS
+		}
+	}
+
+	switch kind {
+	case EPPTransportDNS:
+		return dnsTransport{}
+	case EPPTransportServiceProxy:
+		return serviceProxyTransport{config: cluster.RestConfig}
+	default:
+		return portForwardTransport{cluster: cluster}
+	}
+}
+
+// dnsTransport reaches the publisher proxy by the DNS name of its service
+type dnsTransport struct{}
+
+func (dnsTransport) Kind() EPPTransportKind {
+	return EPPTransportDNS
+}
+
+func (dnsTransport) Connect(context.Context) error {
+	return nil
+}
+
+func (dnsTransport) Send(ctx context.Context, build eppRequestBuilder) (*http.Response, error) {
+	req, err := build(ctx, eppServiceURL)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// serviceProxyTransport reaches the publisher proxy through the service proxy of the API server
+// with the credentials of the kubeconfig, no port-forward is needed
+type serviceProxyTransport struct {
+	config *rest.Config
+}
+
+func (serviceProxyTransport) Kind() EPPTransportKind {
+	return EPPTransportServiceProxy
+}
+
+func (serviceProxyTransport) Connect(context.Context) error {
+	return nil
+}
+
+func (t serviceProxyTransport) Send(ctx context.Context, build eppRequestBuilder) (*http.Response, error) {
+	client, err := rest.HTTPClientFor(t.config)
+	if err != nil {
+		return nil, err
+	}
+	baseURL := fmt.Sprintf("%s/api/v1/namespaces/%s/services/%s:%d/proxy",
+		strings.TrimSuffix(t.config.Host, "/"), eppNamespace, eppServiceName, eppServicePort)
+	req, err := build(ctx, baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// portForwardTransport reaches the publisher proxy through the port-forward of the cluster.
+// The port-forward reconnects by itself when the tunnel breaks. If the proxy is not reachable,
+// the port-forward is asked to reconnect right away and the request is built and sent once more.
+type portForwardTransport struct {
+	cluster *Cluster
+}
+
+func (portForwardTransport) Kind() EPPTransportKind {
+	return EPPTransportPortForward
+}
+
+// Connect opens the port-forward unless the cluster already has one,
+// concurrent requests open only one port-forward
+func (t portForwardTransport) Connect(ctx context.Context) error {
+	t.cluster.connectMu.Lock()
+	defer t.cluster.connectMu.Unlock()
+
+	if t.cluster.Forwarder() != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, eppConnectTimeout)
+	defer cancel()
+	result, err := portForwardEPP(ctx, t.cluster.RestConfig)
+	if err != nil {
+		return &PublishError{Code: PublishErrForwarderUnavailable, Message: err.Error(), status: http.StatusServiceUnavailable}
+	}
+	t.cluster.SetForwarder(result)
+	return nil
+}
+
+func (t portForwardTransport) Send(ctx context.Context, build eppRequestBuilder) (*http.Response, error) {
+	// the cluster has no port-forward if it could not be opened when the cluster was registered
+	if err := t.Connect(ctx); err != nil {
+		return nil, err
+	}
+	result := t.cluster.Forwarder()
+	if result == nil {
+		return nil, &PublishError{Code: PublishErrForwarderUnavailable, Message: "the cluster was removed", status: http.StatusServiceUnavailable}
+	}
+
+	send := func() (*http.Response, error) {
+		baseURL, err := forwardedURL(ctx, result)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			// the port-forward failed and waits to reconnect
+			return nil, &PublishError{Code: PublishErrForwarderUnavailable, Message: err.Error(), status: http.StatusServiceUnavailable}
+		}
+		req, err := build(ctx, baseURL)
+		if err != nil {
+			return nil, err
+		}
+		return http.DefaultClient.Do(req)
+	}
+
+	// the request is only sent again if it could not be sent at all,
+	// a request which may have reached EPP is not published twice
+	response, err := send()
+	if err == nil || ctx.Err() != nil || !isDialError(err) {
+		return response, err
+	}
+
+	result.Reconnect()
+	return send()
+}
+
+// isDialError returns whether the connection to the local port of the port-forward could not be opened
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// forwardedURL returns the local address of the port-forward, the port is the one chosen by the OS.
+// The port-forward never gets ready if the tunnel fails, so the wait ends when ctx is done.
+func forwardedURL(ctx context.Context, result *forwarder.Result) (string, error) {
+	type ready struct {
+		ports [][]portforward.ForwardedPort
+		err   error
+	}
+	readyCh := make(chan ready, 1)
+	go func() {
+		ports, err := result.Ready()
+		readyCh <- ready{ports: ports, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r := <-readyCh:
+		if r.err != nil {
+			return "", r.err
+		}
+		if len(r.ports) == 0 || len(r.ports[0]) == 0 {
+			return "", errors.New("the port-forward has no ports")
+		}
+		return fmt.Sprintf("http://localhost:%d", r.ports[0][0].Local), nil
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/forwarder"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+)
+
+func TestClusterEPPTransport(t *testing.T) {
+	defer func(original func() string) { ownClusterUID = original }(ownClusterUID)
+	ownClusterUID = func() string { return "own" }
+
+	newCluster := func(uid string) *Cluster {
+		return &Cluster{Clientset: fake.NewSimpleClientset(&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: metav1.NamespaceSystem, UID: types.UID(uid)},
+		})}
+	}
+
+	ctx := context.Background()
+	if kind := newCluster("own").EPPTransport(ctx).Kind(); kind != EPPTransportDNS {
+		t.Fatalf("expected DNS inside the cluster, got: %s", kind)
+	}
+	if kind := newCluster("other").EPPTransport(ctx).Kind(); kind != EPPTransportPortForward {
+		t.Fatalf("expected a port-forward outside of the cluster, got: %s", kind)
+	}
+
+	// a dns override of a cluster the backend does not run in is ignored
+	foreign := newCluster("other")
+	foreign.SetEPPTransportOverride(EPPTransportDNS)
+	if kind := foreign.EPPTransport(ctx).Kind(); kind != EPPTransportPortForward {
+		t.Fatalf("expected the dns override of a foreign cluster to be ignored, got: %s", kind)
+	}
+
+	t.Setenv(eppTransportEnv, string(EPPTransportServiceProxy))
+	cluster := newCluster("own")
+	if kind := cluster.EPPTransport(ctx).Kind(); kind != EPPTransportServiceProxy {
+		t.Fatalf("expected the transport of the environment, got: %s", kind)
+	}
+	cluster.SetEPPTransportOverride(EPPTransportAuto)
+	if kind := cluster.EPPTransport(ctx).Kind(); kind != EPPTransportDNS {
+		t.Fatalf("expected the override to win over the environment, got: %s", kind)
+	}
+
+	if _, err := parseEPPTransportKind("ssh"); err == nil {
+		t.Fatal("expected an error for an unknown transport")
+	}
+}
+
+func TestServiceProxyTransport(t *testing.T) {
+	var received *http.Request
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer apiServer.Close()
+
+	cluster := &Cluster{RestConfig: &rest.Config{Host: apiServer.URL + "/", BearerToken: "token"}}
+	cluster.SetEPPTransportOverride(EPPTransportServiceProxy)
+
+	response, err := cluster.EPPTransport(context.Background()).Send(context.Background(),
+		func(ctx context.Context, baseURL string) (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/publish", strings.NewReader(`{}`))
+		})
+	if err != nil {
+		t.Fatalf("failed to send through the service proxy: %v", err)
+	}
+	response.Body.Close()
+
+	if received.URL.Path != "/api/v1/namespaces/kyma-system/services/eventing-publisher-proxy:80/proxy/publish" {
+		t.Fatalf("unexpected service proxy path: %s", received.URL.Path)
+	}
+	if received.Header.Get("Authorization") != "Bearer token" {
+		t.Fatalf("expected the credentials of the kubeconfig, got: %v", received.Header)
+	}
+}
+
+func TestForwardedURL(t *testing.T) {
+	result := &forwarder.Result{Ready: func() ([][]portforward.ForwardedPort, error) {
+		return [][]portforward.ForwardedPort{{{Local: 41234, Remote: eppPodPort}}}, nil
+	}}
+	if baseURL, err := forwardedURL(context.Background(), result); err != nil || baseURL != "http://localhost:41234" {
+		t.Fatalf("expected the local port chosen by the OS, got %q: %v", baseURL, err)
+	}
+
+	// a broken tunnel never gets ready
+	broken := make(chan struct{})
+	defer close(broken)
+	never := &forwarder.Result{Ready: func() ([][]portforward.ForwardedPort, error) {
+		<-broken
+		return nil, errors.New("lost connection to pod")
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := forwardedURL(ctx, never); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the wait to end with the context, got: %v", err)
+	}
+}
+
+func TestPortForwardTransportRetry(t *testing.T) {
+	var requests int32
+	received := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		// the connection breaks after EPP received the request
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer received.Close()
+	published := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer published.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	var port, reconnects int32
+	cluster := &Cluster{}
+	cluster.SetForwarder(&forwarder.Result{
+		Close: func() {},
+		Ready: func() ([][]portforward.ForwardedPort, error) {
+			return [][]portforward.ForwardedPort{{{Local: uint16(atomic.LoadInt32(&port)), Remote: eppPodPort}}}, nil
+		},
+		Reconnect: func() {
+			atomic.AddInt32(&reconnects, 1)
+			atomic.StoreInt32(&port, int32(published.Listener.Addr().(*net.TCPAddr).Port))
+		},
+	})
+	transport := portForwardTransport{cluster: cluster}
+	build := func(ctx context.Context, baseURL string) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/publish", strings.NewReader("{}"))
+	}
+
+	// a request which may have reached EPP is not sent again
+	atomic.StoreInt32(&port, int32(received.Listener.Addr().(*net.TCPAddr).Port))
+	if _, err := transport.Send(context.Background(), build); err == nil {
+		t.Fatal("expected the broken connection to fail the request")
+	}
+	if atomic.LoadInt32(&requests) != 1 || atomic.LoadInt32(&reconnects) != 0 {
+		t.Fatalf("expected the request to be sent once without reconnect, got %d requests, %d reconnects", requests, reconnects)
+	}
+
+	// a request which could not be sent is sent again after the reconnect
+	atomic.StoreInt32(&requests, 0)
+	atomic.StoreInt32(&port, int32(refused))
+	response, err := transport.Send(context.Background(), build)
+	if err != nil {
+		t.Fatalf("expected the request to be sent after the reconnect, got: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNoContent || atomic.LoadInt32(&requests) != 1 || atomic.LoadInt32(&reconnects) != 1 {
+		t.Fatalf("expected one request after one reconnect, got %d, %d requests, %d reconnects", response.StatusCode, requests, reconnects)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// eventingConfigMap is the ConfigMap in kyma-system which configures the eventing publisher proxy
+	eventingConfigMap = "eventing"
+	// eventingConfigMapPrefixKey is the key of the event type prefix in the eventing ConfigMap
+	eventingConfigMapPrefixKey = "eventTypePrefix"
+	// eventingControllerDeployment is read if the eventing ConfigMap does not exist
+	eventingControllerDeployment = "eventing-controller"
+	// eventingControllerPrefixEnv is the environment variable of the eventing controller with the event type prefix
+	eventingControllerPrefixEnv = "EVENT_TYPE_PREFIX"
+)
+
+var (
+	// prefixPattern matches dot separated segments, e.g. sap.kyma.custom
+	prefixPattern = regexp.MustCompile(`^[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)*$`)
+	// segmentPattern matches the application name and the version, which must not contain dots
+	segmentPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	// eventNamePattern matches an event name of one or more dot separated segments, e.g. order.created
+	eventNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+(\.[a-zA-Z0-9_-]+)*$`)
+)
+
+// discoverEventTypePrefix reads the event type prefix configured in the cluster from the eventing ConfigMap
+// or the eventing controller deployment, an empty prefix is returned if neither configures one
+func discoverEventTypePrefix(ctx context.Context, clientset kubernetes.Interface) (string, error) {
+	configMap, err := clientset.CoreV1().ConfigMaps("kyma-system").Get(ctx, eventingConfigMap, metav1.GetOptions{})
+	if err == nil {
+		if prefix := configMap.Data[eventingConfigMapPrefixKey]; prefix != "" {
+			return prefix, nil
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return "", err
+	}
+
+	deployment, err := clientset.AppsV1().Deployments("kyma-system").Get(ctx, eventingControllerDeployment, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == eventingControllerPrefixEnv && env.Value != "" {
+				return env.Value, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// validateEventTypePrefix checks the prefix follows the Kyma naming rules
+func validateEventTypePrefix(prefix string) error {
+	if !prefixPattern.MatchString(prefix) {
+		return fmt.Errorf("invalid event type prefix %q: expected dot separated alphanumeric segments", prefix)
+	}
+	return nil
+}
+
+// validateEventTypeSegments checks the application, event and version follow the Kyma naming rules:
+// the application and version must not contain dots, the event consists of dot separated segments,
+// usually a business object and an operation
+func validateEventTypeSegments(appName, eventName, eventVersion string) error {
+	var errs []string
+	if !segmentPattern.MatchString(appName) {
+		errs = append(errs, fmt.Sprintf("invalid appName %q: only alphanumeric characters, '-' and '_' are allowed", appName))
+	}
+	if !eventNamePattern.MatchString(eventName) {
+		errs = append(errs, fmt.Sprintf("invalid eventName %q: expected dot separated alphanumeric segments, e.g. order.created", eventName))
+	}
+	if !segmentPattern.MatchString(eventVersion) {
+		errs = append(errs, fmt.Sprintf("invalid eventVersion %q: only alphanumeric characters, '-' and '_' are allowed", eventVersion))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDiscoverEventTypePrefix(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "eventing-controller", Namespace: "kyma-system"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name: "controller",
+				Env:  []corev1.EnvVar{{Name: "EVENT_TYPE_PREFIX", Value: "com.deployment"}},
+			}},
+		}}},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "eventing", Namespace: "kyma-system"},
+		Data:       map[string]string{"eventTypePrefix": "com.configmap"},
+	}
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		want    string
+	}{
+		{name: "ConfigMap takes precedence", objects: []runtime.Object{deployment, configMap}, want: "com.configmap"},
+		{name: "deployment env", objects: []runtime.Object{deployment}, want: "com.deployment"},
+		{name: "nothing configured"},
+	}
+
+	for _, tc := range tests {
+		prefix, err := discoverEventTypePrefix(context.Background(), fake.NewSimpleClientset(tc.objects...))
+		if err != nil || prefix != tc.want {
+			t.Fatalf("%s: expected prefix %q, got %q, %v", tc.name, tc.want, prefix, err)
+		}
+	}
+
+	cluster := &Cluster{Clientset: fake.NewSimpleClientset()}
+	if prefix, err := cluster.EventTypePrefix(context.Background()); err != nil || prefix != defaultEventTypePrefix {
+		t.Fatalf("expected the default prefix, got %q, %v", prefix, err)
+	}
+	cluster.SetEventTypePrefixOverride("com.override")
+	if prefix, err := cluster.EventTypePrefix(context.Background()); err != nil || prefix != "com.override" {
+		t.Fatalf("expected the override, got %q, %v", prefix, err)
+	}
+
+	// a kubeconfig which must not read kyma-system uses the default prefix, the cluster is asked only once
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("get", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(corev1.Resource("configmaps"), eventingConfigMap, errors.New("not allowed"))
+	})
+	forbidden := &Cluster{Clientset: clientset}
+	for i := 0; i < 2; i++ {
+		if prefix, err := forbidden.EventTypePrefix(context.Background()); err != nil || prefix != defaultEventTypePrefix {
+			t.Fatalf("expected the default prefix if the configuration is forbidden, got %q, %v", prefix, err)
+		}
+	}
+	if actions := len(clientset.Actions()); actions != 1 {
+		t.Fatalf("expected the fallback to be cached, got %d requests", actions)
+	}
+}
+
+func TestValidateEventTypeSegments(t *testing.T) {
+	for _, eventName := range []string{"order", "order.created", "sales.order.created", "order_line-item.created"} {
+		if err := validateEventTypeSegments("shop", eventName, "v1"); err != nil {
+			t.Fatalf("expected the event name %q to be valid, got: %v", eventName, err)
+		}
+	}
+	for _, eventName := range []string{"", "order.", ".created", "order..created", "order created"} {
+		if err := validateEventTypeSegments("shop", eventName, "v1"); err == nil {
+			t.Fatalf("expected the event name %q to be invalid", eventName)
+		}
+	}
+	if err := validateEventTypeSegments("my.shop", "order.created", "v.1"); err == nil {
+		t.Fatal("expected an application and a version with dots to be invalid")
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	serverlessv1alpha1 "github.com/kyma-project/kyma/components/function-controller/pkg/apis/serverless/v1alpha1"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/function"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// supportedRuntimes are the function runtimes supported by the serverless controller
+var supportedRuntimes = []serverlessv1alpha1.Runtime{
+	serverlessv1alpha1.Nodejs14,
+	serverlessv1alpha1.Nodejs16,
+	serverlessv1alpha1.Python39,
+}
+
+const (
+	defaultRuntime     = serverlessv1alpha1.Nodejs16
+	defaultMinReplicas = 1
+	defaultMaxReplicas = 5
+)
+
+// resourceProfiles are the presets of the function resources, see serverlessv1alpha1.FunctionResourcesPresetLabel
+var resourceProfiles = []string{"XS", "S", "M", "L", "XL"}
+
+// errFunctionChanged is returned when the function was changed since the client loaded it
+var errFunctionChanged = errors.New("the function was changed in the meantime, reload it and apply your changes again")
+
+// FunctionData is the request body to create or update a function, only the given fields are changed
+type FunctionData struct {
+	Source          *string                      `json:"source,omitempty"`
+	SourceCode      *string                      `json:"sourceCode,omitempty"` // SourceCode is an alias of Source
+	Deps            *string                      `json:"deps,omitempty"`
+	Runtime         *string                      `json:"runtime,omitempty"`
+	Env             *[]corev1.EnvVar             `json:"env,omitempty"` // an empty list removes all env vars
+	MinReplicas     *int32                       `json:"minReplicas,omitempty"`
+	MaxReplicas     *int32                       `json:"maxReplicas,omitempty"`
+	Labels          map[string]string            `json:"labels,omitempty"`          // replaces the labels, the resource profile is kept
+	ResourceProfile *string                      `json:"resourceProfile,omitempty"` // XS, S, M, L or XL, an empty profile removes it
+	Resources       *corev1.ResourceRequirements `json:"resources,omitempty"`       // custom resources replace the profile
+	Git             *GitSourceData               `json:"git,omitempty"`             // Git replaces the inline source
+
+	// ResourceVersion is the version of the function the changes are based on,
+	// the update is rejected if the function was changed since
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// applyFunctionData changes the function by the given fields of the data
+func applyFunctionData(fn *serverlessv1alpha1.Function, data FunctionData) error {
+	if data.ResourceVersion != "" && data.ResourceVersion != fn.ResourceVersion {
+		return errFunctionChanged
+	}
+	if data.ResourceProfile != nil && data.Resources != nil {
+		return fmt.Errorf("resourceProfile and resources must not be given both")
+	}
+
+	spec := &fn.Spec
+	if data.Source == nil {
+		data.Source = data.SourceCode
+	}
+	if data.Git != nil {
+		if data.Source != nil || data.Deps != nil {
+			return fmt.Errorf("source and deps must not be given for a git source")
+		}
+		if err := applyGitSource(fn, *data.Git); err != nil {
+			return err
+		}
+	} else if data.Source != nil && spec.Type == serverlessv1alpha1.SourceTypeGit {
+		// an inline source replaces the git source
+		spec.Type = ""
+		spec.Repository = serverlessv1alpha1.Repository{}
+	}
+	if data.Source != nil {
+		spec.Source = *data.Source
+	}
+	if data.Deps != nil {
+		spec.Deps = *data.Deps
+	}
+	if data.Runtime != nil {
+		runtime, err := validateRuntime(*data.Runtime)
+		if err != nil {
+			return err
+		}
+		spec.Runtime = runtime
+	}
+	if data.Env != nil {
+		spec.Env = *data.Env
+	}
+	if data.MinReplicas != nil {
+		spec.MinReplicas = data.MinReplicas
+	}
+	if data.MaxReplicas != nil {
+		spec.MaxReplicas = data.MaxReplicas
+	}
+	if err := validateReplicas(spec.MinReplicas, spec.MaxReplicas); err != nil {
+		return err
+	}
+
+	if data.Labels != nil {
+		labels := make(map[string]string, len(data.Labels))
+		for key, value := range data.Labels {
+			labels[key] = value
+		}
+		if profile, ok := fn.Labels[serverlessv1alpha1.FunctionResourcesPresetLabel]; ok {
+			labels[serverlessv1alpha1.FunctionResourcesPresetLabel] = profile
+		}
+		fn.Labels = labels
+	}
+
+	if data.ResourceProfile != nil {
+		if err := setResourceProfile(fn, *data.ResourceProfile); err != nil {
+			return err
+		}
+	}
+	if data.Resources != nil {
+		delete(fn.Labels, serverlessv1alpha1.FunctionResourcesPresetLabel)
+		spec.Resources = *data.Resources
+	}
+
+	return nil
+}
+
+// newFunction returns the function defined by the data, the missing fields are defaulted.
+// A function without inline or git source is created from the template of its runtime,
+// the default template is used if no template name is given.
+func newFunction(name, namespace string, data FunctionData, templateName string) (*serverlessv1alpha1.Function, error) {
+	minReplicas := int32(defaultMinReplicas)
+	maxReplicas := int32(defaultMaxReplicas)
+	if data.MaxReplicas == nil && data.MinReplicas != nil && *data.MinReplicas > maxReplicas {
+		maxReplicas = *data.MinReplicas
+	}
+
+	if templateName != "" && (data.Source != nil || data.SourceCode != nil || data.Git != nil) {
+		return nil, fmt.Errorf("the template %s can not be combined with a source", templateName)
+	}
+
+	runtime := defaultRuntime
+	// a template which is not available for the default runtime defaults to its first runtime
+	if _, ok := functionTemplates.Get(templateName, string(defaultRuntime)); data.Runtime == nil && templateName != "" && !ok {
+		if runtimes := functionTemplates.Runtimes(templateName); len(runtimes) > 0 {
+			runtime = serverlessv1alpha1.Runtime(runtimes[0])
+		}
+	}
+
+	fn := &serverlessv1alpha1.Function{
+		Spec: serverlessv1alpha1.FunctionSpec{
+			Runtime:     runtime,
+			MinReplicas: &minReplicas,
+			MaxReplicas: &maxReplicas,
+		},
+	}
+	fn.APIVersion = "serverless.kyma-project.io/v1alpha1"
+	fn.Kind = "Function"
+	fn.Name = name
+	fn.Namespace = namespace
+
+	// a new function has no version the changes could be based on
+	data.ResourceVersion = ""
+	if err := applyFunctionData(fn, data); err != nil {
+		return nil, err
+	}
+
+	if fn.Spec.Source == "" && fn.Spec.Type != serverlessv1alpha1.SourceTypeGit {
+		if templateName == "" {
+			templateName = defaultTemplate
+		}
+		template, ok := functionTemplates.Get(templateName, string(fn.Spec.Runtime))
+		if !ok {
+			return nil, fmt.Errorf("the template %s does not exist for the runtime %s", templateName, fn.Spec.Runtime)
+		}
+		fn.Spec.Source = template.Source
+		if data.Deps == nil {
+			fn.Spec.Deps = template.Deps
+		}
+	}
+
+	return fn, nil
+}
+
+// validateRuntime returns the runtime or an error if it is not supported
+func validateRuntime(runtime string) (serverlessv1alpha1.Runtime, error) {
+	for _, supported := range supportedRuntimes {
+		if serverlessv1alpha1.Runtime(runtime) == supported {
+			return supported, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported runtime %q, expected one of %v", runtime, supportedRuntimes)
+}
+
+func validateReplicas(minReplicas, maxReplicas *int32) error {
+	if minReplicas != nil && *minReplicas < 1 {
+		return fmt.Errorf("minReplicas must be at least 1")
+	}
+	if maxReplicas != nil && *maxReplicas < 1 {
+		return fmt.Errorf("maxReplicas must be at least 1")
+	}
+	if minReplicas != nil && maxReplicas != nil && *minReplicas > *maxReplicas {
+		return fmt.Errorf("minReplicas %d must not exceed maxReplicas %d", *minReplicas, *maxReplicas)
+	}
+	return nil
+}
+
+// setResourceProfile sets the resource preset label of the function, an empty profile removes it.
+// The resources are reset, so that the serverless controller applies the preset.
+func setResourceProfile(fn *serverlessv1alpha1.Function, profile string) error {
+	if profile == "" {
+		delete(fn.Labels, serverlessv1alpha1.FunctionResourcesPresetLabel)
+		fn.Spec.Resources = corev1.ResourceRequirements{}
+		return nil
+	}
+
+	for _, supported := range resourceProfiles {
+		if profile == supported {
+			if fn.Labels == nil {
+				fn.Labels = make(map[string]string)
+			}
+			fn.Labels[serverlessv1alpha1.FunctionResourcesPresetLabel] = profile
+			fn.Spec.Resources = corev1.ResourceRequirements{}
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported resource profile %q, expected one of %v", profile, resourceProfiles)
+}
+
+func postFunction(w http.ResponseWriter, r *http.Request) {
+	// Fetch data from URI
+	name := mux.Vars(r)["name"]
+	namespace := mux.Vars(r)["ns"]
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	// Fetch data from request body, an empty body creates the default function
+	var data FunctionData
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	git, repository, err := gitSourceRepository(name, namespace, data.Git)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data.Git = git
+	fn, err := newFunction(name, namespace, data, r.URL.Query().Get("template"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cluster := clusterFromContext(r.Context())
+	fnUnstructured, err := cluster.FunctionClient.CreateFunction(*fn)
+	if apierrors.IsAlreadyExists(err) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The git repository is only created or updated for a created function, the function is removed if this fails
+	if repository != nil {
+		_, err := upsertGitRepository(cluster.GitRepositoryClient, *repository)
+		if err != nil {
+			if deleteErr := cluster.FunctionClient.DeleteFunction(name, namespace); deleteErr != nil {
+				log.Printf("%s %s failed to remove the function: %v", r.Method, r.RequestURI, deleteErr)
+			}
+		}
+		if apierrors.IsConflict(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("%s %s failed to create git repository: %v", r.Method, r.RequestURI, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Convert response to bytes
+	fnBytes, err := fnUnstructured.MarshalJSON()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response to user
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(fnBytes)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
+
+func putFunction(w http.ResponseWriter, r *http.Request) {
+	logHitEndpoint(r.RequestURI)
+	// Fetch data from URI
+	name := mux.Vars(r)["name"]
+	namespace := mux.Vars(r)["ns"]
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	// Fetch data from request body
+	var data FunctionData
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	git, repository, err := gitSourceRepository(name, namespace, data.Git)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data.Git = git
+
+	// The data is applied to the latest version of the function, again on every conflict
+	cluster := clusterFromContext(r.Context())
+	var dataErr error
+	var previous, updated serverlessv1alpha1.FunctionSpec
+	fnUnstructured, err := cluster.FunctionClient.UpdateFunction(name, namespace, func(fn *serverlessv1alpha1.Function) error {
+		previous = *fn.Spec.DeepCopy()
+		dataErr = applyFunctionData(fn, data)
+		updated = fn.Spec
+		return dataErr
+	})
+	if apierrors.IsNotFound(err) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errFunctionChanged) || apierrors.IsConflict(err) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil && err == dataErr {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if repository != nil {
+		_, err := upsertGitRepository(cluster.GitRepositoryClient, *repository)
+		if err != nil {
+			// The function must not reference a git repository which could not be updated
+			if restoreErr := restoreFunctionSpec(cluster.FunctionClient, fnUnstructured, previous); restoreErr != nil {
+				log.Printf("%s %s failed to restore the function: %v", r.Method, r.RequestURI, restoreErr)
+			}
+		}
+		if apierrors.IsConflict(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("%s %s failed to update git repository: %v", r.Method, r.RequestURI, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// The git repository the function used before is removed if no function uses it anymore
+	if previous.Type == serverlessv1alpha1.SourceTypeGit && (updated.Type != serverlessv1alpha1.SourceTypeGit || updated.Source != previous.Source) {
+		if err := deleteOrphanedGitRepository(cluster, previous.Source, namespace); err != nil {
+			log.Printf("%s %s failed to remove the git repository %s: %v", r.Method, r.RequestURI, previous.Source, err)
+		}
+	}
+
+	// Convert response to bytes
+	fnBytes, err := fnUnstructured.MarshalJSON()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response to user
+	_, err = w.Write(fnBytes)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
+
+// restoreFunctionSpec restores the spec of the function unless it was changed since the given update
+func restoreFunctionSpec(client function.Client, fnUnstructured *unstructured.Unstructured, spec serverlessv1alpha1.FunctionSpec) error {
+	_, err := client.UpdateFunction(fnUnstructured.GetName(), fnUnstructured.GetNamespace(), func(fn *serverlessv1alpha1.Function) error {
+		if fn.ResourceVersion != fnUnstructured.GetResourceVersion() {
+			return errFunctionChanged
+		}
+		fn.Spec = spec
+		return nil
+	})
+	return err
+}
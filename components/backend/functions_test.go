@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	serverlessv1alpha1 "github.com/kyma-project/kyma/components/function-controller/pkg/apis/serverless/v1alpha1"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/function"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/templates"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestFunction() *serverlessv1alpha1.Function {
+	replicas := int32(1)
+	fn := &serverlessv1alpha1.Function{
+		Spec: serverlessv1alpha1.FunctionSpec{
+			Source:      "module.exports = {}",
+			Runtime:     serverlessv1alpha1.Nodejs16,
+			MinReplicas: &replicas,
+			MaxReplicas: &replicas,
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+			},
+		},
+	}
+	fn.APIVersion = "serverless.kyma-project.io/v1alpha1"
+	fn.Kind = "Function"
+	fn.Name = "test"
+	fn.Namespace = "default"
+	fn.ResourceVersion = "1"
+	return fn
+}
+
+func TestApplyFunctionData(t *testing.T) {
+	source := "def main(event, context): pass"
+	runtime := "python39"
+	profile := "L"
+	maxReplicas := int32(3)
+	env := []corev1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}}
+
+	fn := newTestFunction()
+	err := applyFunctionData(fn, FunctionData{
+		Source:          &source,
+		Runtime:         &runtime,
+		Env:             &env,
+		MaxReplicas:     &maxReplicas,
+		ResourceProfile: &profile,
+		ResourceVersion: "1",
+	})
+	if err != nil {
+		t.Fatalf("failed to apply data: %v", err)
+	}
+
+	if fn.Spec.Source != source || fn.Spec.Runtime != serverlessv1alpha1.Python39 || len(fn.Spec.Env) != 1 {
+		t.Fatalf("unexpected spec: %+v", fn.Spec)
+	}
+	if *fn.Spec.MinReplicas != 1 || *fn.Spec.MaxReplicas != 3 {
+		t.Fatalf("expected only maxReplicas to change, got %d-%d", *fn.Spec.MinReplicas, *fn.Spec.MaxReplicas)
+	}
+	if fn.Labels[serverlessv1alpha1.FunctionResourcesPresetLabel] != "L" || fn.Spec.Resources.Limits != nil {
+		t.Fatalf("expected the profile to replace the resources, got %v %+v", fn.Labels, fn.Spec.Resources)
+	}
+}
+
+func TestApplyFunctionDataInvalid(t *testing.T) {
+	runtime := "nodejs10"
+	profile := "XXL"
+	zero := int32(0)
+	two := int32(2)
+
+	tests := map[string]FunctionData{
+		"unknown runtime": {Runtime: &runtime},
+		"unknown profile": {ResourceProfile: &profile},
+		"zero replicas":   {MinReplicas: &zero},
+		"min exceeds max": {MinReplicas: &two},
+		"profile and resources": {
+			ResourceProfile: new(string),
+			Resources:       &corev1.ResourceRequirements{},
+		},
+	}
+
+	for name, data := range tests {
+		if err := applyFunctionData(newTestFunction(), data); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+
+	if err := applyFunctionData(newTestFunction(), FunctionData{ResourceVersion: "0"}); !errors.Is(err, errFunctionChanged) {
+		t.Fatalf("expected errFunctionChanged, got: %v", err)
+	}
+}
+
+func TestPutFunction(t *testing.T) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(newTestFunction())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeDynamicClient(&unstructured.Unstructured{Object: object})
+	cluster := &Cluster{FunctionClient: function.NewClient(client)}
+
+	put := func(name, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/default/funcs/"+name, strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"ns": "default", "name": name})
+		req = req.WithContext(context.WithValue(req.Context(), clusterContextKey{}, cluster))
+		rec := httptest.NewRecorder()
+		putFunction(rec, req)
+		return rec
+	}
+
+	if rec := put("test", `{"source": "module.exports = { main: () => 'updated' }"}`); rec.Code != http.StatusOK ||
+		!strings.Contains(rec.Body.String(), "updated") {
+		t.Fatalf("expected the updated function, got %d: %s", rec.Code, rec.Body.String())
+	}
+	fn, err := cluster.FunctionClient.GetFn("test", "default")
+	if err != nil || !strings.Contains(fn.Spec.Source, "updated") || fn.Spec.Runtime != serverlessv1alpha1.Nodejs16 {
+		t.Fatalf("expected only the source to be updated, got %+v, %v", fn, err)
+	}
+
+	if rec := put("missing", `{}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown function, got %d", rec.Code)
+	}
+	if rec := put("test", `{"runtime": "go"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown runtime, got %d", rec.Code)
+	}
+	if rec := put("test", `{"source": "lost", "resourceVersion": "outdated"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for an outdated resource version, got %d", rec.Code)
+	}
+}
+
+func TestPutFunctionConcurrentChange(t *testing.T) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(newTestFunction())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeDynamicClient(&unstructured.Unstructured{Object: object})
+	cluster := &Cluster{FunctionClient: function.NewClient(client)}
+
+	// the function is changed by someone else between the read and the first update
+	changed, version := false, 1
+	client.PrependReactor("update", "functions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if changed {
+			return false, nil, nil
+		}
+		changed = true
+		current, err := client.Tracker().Get(function.GroupVersionResource(), "default", "test")
+		if err != nil {
+			return true, nil, err
+		}
+		version++
+		fn := current.(*unstructured.Unstructured).DeepCopy()
+		fn.SetResourceVersion(strconv.Itoa(version))
+		if err := unstructured.SetNestedField(fn.Object, "python39", "spec", "runtime"); err != nil {
+			return true, nil, err
+		}
+		if err := client.Tracker().Update(function.GroupVersionResource(), fn, "default"); err != nil {
+			return true, nil, err
+		}
+		return true, nil, apierrors.NewConflict(function.GroupVersionResource().GroupResource(), "test", errors.New("the object has been modified"))
+	})
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/default/funcs/test", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"ns": "default", "name": "test"})
+		req = req.WithContext(context.WithValue(req.Context(), clusterContextKey{}, cluster))
+		rec := httptest.NewRecorder()
+		putFunction(rec, req)
+		return rec
+	}
+
+	if rec := put(`{"source": "def main(event, context): return 'updated'"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected the update to be retried, got %d: %s", rec.Code, rec.Body.String())
+	}
+	fn, err := cluster.FunctionClient.GetFn("test", "default")
+	if err != nil || !strings.Contains(fn.Spec.Source, "updated") || fn.Spec.Runtime != serverlessv1alpha1.Python39 {
+		t.Fatalf("expected the concurrent change to be kept, got %+v, %v", fn, err)
+	}
+
+	// a client which read the function before the change is rejected
+	changed = false
+	if rec := put(`{"source": "lost", "resourceVersion": "2"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a change since the given resource version, got %d", rec.Code)
+	}
+	if fn, err := cluster.FunctionClient.GetFn("test", "default"); err != nil || fn.Spec.Source == "lost" {
+		t.Fatalf("expected the function not to be updated, got %+v, %v", fn, err)
+	}
+}
+
+func TestNewFunction(t *testing.T) {
+	helloNodejs, _ := functionTemplates.Get(defaultTemplate, "nodejs16")
+	helloPython, _ := functionTemplates.Get(defaultTemplate, "python39")
+	forwardNodejs, _ := functionTemplates.Get("forward-http", "nodejs14")
+
+	tests := []struct {
+		name        string
+		data        FunctionData
+		template    string
+		wantRuntime serverlessv1alpha1.Runtime
+		wantSource  string
+		wantDeps    string
+		wantMax     int32
+	}{
+		{
+			name:        "default function",
+			wantRuntime: serverlessv1alpha1.Nodejs16,
+			wantSource:  helloNodejs.Source,
+			wantDeps:    helloNodejs.Deps,
+			wantMax:     defaultMaxReplicas,
+		},
+		{
+			name:        "python template",
+			data:        FunctionData{Runtime: stringPtr("python39")},
+			wantRuntime: serverlessv1alpha1.Python39,
+			wantSource:  helloPython.Source,
+			wantDeps:    helloPython.Deps,
+			wantMax:     defaultMaxReplicas,
+		},
+		{
+			name:        "named template",
+			data:        FunctionData{Runtime: stringPtr("nodejs14"), Deps: stringPtr("{}")},
+			template:    "forward-http",
+			wantRuntime: serverlessv1alpha1.Nodejs14,
+			wantSource:  forwardNodejs.Source,
+			wantDeps:    "{}",
+			wantMax:     defaultMaxReplicas,
+		},
+		{
+			name:        "source code of the frontend",
+			data:        FunctionData{Runtime: stringPtr("nodejs14"), SourceCode: stringPtr("module.exports = {}")},
+			wantRuntime: serverlessv1alpha1.Nodejs14,
+			wantSource:  "module.exports = {}",
+			wantMax:     defaultMaxReplicas,
+		},
+		{
+			name:        "scaled function",
+			data:        FunctionData{Source: stringPtr("module.exports = {}"), Deps: stringPtr("{}"), MinReplicas: int32Ptr(8)},
+			wantRuntime: serverlessv1alpha1.Nodejs16,
+			wantSource:  "module.exports = {}",
+			wantDeps:    "{}",
+			wantMax:     8,
+		},
+	}
+
+	for _, tc := range tests {
+		fn, err := newFunction("test", "default", tc.data, tc.template)
+		if err != nil {
+			t.Fatalf("%s: failed to create function: %v", tc.name, err)
+		}
+		if fn.Spec.Runtime != tc.wantRuntime || fn.Spec.Source != tc.wantSource || fn.Spec.Deps != tc.wantDeps {
+			t.Fatalf("%s: unexpected spec: %+v", tc.name, fn.Spec)
+		}
+		if *fn.Spec.MaxReplicas != tc.wantMax || fn.Name != "test" || fn.Kind != "Function" {
+			t.Fatalf("%s: unexpected function: %+v", tc.name, fn)
+		}
+	}
+
+	if _, err := newFunction("test", "default", FunctionData{Runtime: stringPtr("nodejs12")}, ""); err == nil {
+		t.Fatal("expected an error for an unsupported runtime")
+	}
+	if _, err := newFunction("test", "default", FunctionData{}, "missing"); err == nil {
+		t.Fatal("expected an error for an unknown template")
+	}
+	if _, err := newFunction("test", "default", FunctionData{Source: stringPtr("module.exports = {}")}, "echo-event"); err == nil {
+		t.Fatal("expected an error for a template with source")
+	}
+}
+
+func TestNewFunctionTemplateRuntime(t *testing.T) {
+	if err := functionTemplates.Add(templates.Template{Name: "python-only", Runtimes: []string{"python39"}, Source: "def main(event, context):\n    return event\n"}); err != nil {
+		t.Fatal(err)
+	}
+
+	fn, err := newFunction("test", "default", FunctionData{}, "python-only")
+	if err != nil {
+		t.Fatalf("failed to create function: %v", err)
+	}
+	if fn.Spec.Runtime != serverlessv1alpha1.Python39 {
+		t.Fatalf("expected the runtime of the template, got: %s", fn.Spec.Runtime)
+	}
+}
+
+func TestGetTemplates(t *testing.T) {
+	rec := httptest.NewRecorder()
+	getTemplates(rec, httptest.NewRequest(http.MethodGet, "/api/templates?runtime=python39", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var listed []templates.Template
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatal(err)
+	}
+	for _, template := range listed {
+		if len(template.Runtimes) != 1 || template.Runtimes[0] != "python39" {
+			t.Fatalf("expected python templates only, got: %+v", template)
+		}
+	}
+	if len(listed) < 4 {
+		t.Fatalf("expected the builtin python templates, got: %+v", listed)
+	}
+
+	rec = httptest.NewRecorder()
+	getTemplates(rec, httptest.NewRequest(http.MethodGet, "/api/templates?runtime=nodejs12", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unsupported runtime, got %d", rec.Code)
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
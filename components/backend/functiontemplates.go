@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/vladislavpaskar/hackathon2022/components/backend/templates"
+)
+
+const (
+	// templatesDirEnv is a directory with additional function templates
+	templatesDirEnv = "TEMPLATES_DIR"
+	// templatesConfigMapEnv is a ConfigMap with additional function templates as [<namespace>/]<name>,
+	// the namespace defaults to the namespace of the pod
+	templatesConfigMapEnv = "TEMPLATES_CONFIGMAP"
+
+	// defaultTemplate is the template of a function created without source
+	defaultTemplate = "hello-world"
+)
+
+// functionTemplates is the catalog of the templates a function can be created from
+var functionTemplates = newBuiltinCatalog()
+
+func newBuiltinCatalog() *templates.Catalog {
+	catalog, err := templates.NewCatalog(templates.Builtin()...)
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
+
+// loadFunctionTemplates adds the templates of the directory and the ConfigMap configured by the
+// environment to the catalog, they replace the builtin templates with the same name and runtime
+func loadFunctionTemplates() {
+	if dir := os.Getenv(templatesDirEnv); dir != "" {
+		loaded, err := templates.LoadDir(dir)
+		if err == nil {
+			err = functionTemplates.Add(loaded...)
+		}
+		if err != nil {
+			log.Printf("failed to load templates from %s: %v", dir, err)
+		} else {
+			log.Printf("loaded %d templates from %s", len(loaded), dir)
+		}
+	}
+
+	if ref := os.Getenv(templatesConfigMapEnv); ref != "" {
+		namespace, name := ownNamespace(), ref
+		if parts := strings.SplitN(ref, "/", 2); len(parts) == 2 {
+			namespace, name = parts[0], parts[1]
+		}
+
+		var loaded []templates.Template
+		clientset, err := inClusterClientset()
+		if err == nil {
+			loaded, err = templates.LoadConfigMap(context.Background(), clientset, namespace, name)
+		}
+		if err == nil {
+			err = functionTemplates.Add(loaded...)
+		}
+		if err != nil {
+			log.Printf("failed to load templates from ConfigMap %s/%s: %v", namespace, name, err)
+		} else {
+			log.Printf("loaded %d templates from ConfigMap %s/%s", len(loaded), namespace, name)
+		}
+	}
+}
+
+func getTemplates(w http.ResponseWriter, r *http.Request) {
+	// Fetch runtime info from the query parameters
+	runtime := r.URL.Query().Get("runtime")
+	if runtime != "" {
+		if _, err := validateRuntime(runtime); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Convert response to bytes
+	data, err := json.Marshal(functionTemplates.List(runtime))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response to user
+	_, err = w.Write(data)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	serverlessv1alpha1 "github.com/kyma-project/kyma/components/function-controller/pkg/apis/serverless/v1alpha1"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/gitrepository"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// defaultBaseDir is the directory of the function in the git repository if no base directory is given
+	defaultBaseDir = "/"
+	// gitRepositoryManagedLabel marks the git repositories created by the backend,
+	// only these are updated by the git source of a function
+	gitRepositoryManagedLabel = "hackathon2022.kyma-project.io/managed"
+)
+
+// GitRepositoryData is the request body to create or update a git repository
+type GitRepositoryData struct {
+	URL  string                             `json:"url"`
+	Auth *serverlessv1alpha1.RepositoryAuth `json:"auth,omitempty"` // Auth references the Secret with the credentials
+}
+
+// GitSourceData is the git source of a function
+type GitSourceData struct {
+	// Repository is the name of the GitRepository, it defaults to the name of the function if the URL is given
+	Repository string `json:"repository,omitempty"`
+	// URL and Auth create or update the GitRepository
+	URL       string                             `json:"url,omitempty"`
+	Auth      *serverlessv1alpha1.RepositoryAuth `json:"auth,omitempty"`
+	Reference string                             `json:"reference"`
+	BaseDir   string                             `json:"baseDir,omitempty"`
+}
+
+// buildGitRepository returns the git repository defined by the data or an error if it is invalid
+func buildGitRepository(name, namespace string, data GitRepositoryData) (*serverlessv1alpha1.GitRepository, error) {
+	repository := &serverlessv1alpha1.GitRepository{
+		Spec: serverlessv1alpha1.GitRepositorySpec{
+			URL:  data.URL,
+			Auth: data.Auth,
+		},
+	}
+	repository.Name = name
+	repository.Namespace = namespace
+	repository.Labels = map[string]string{gitRepositoryManagedLabel: "true"}
+
+	if err := repository.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid git repository: %w", err)
+	}
+	return repository, nil
+}
+
+// gitSourceRepository returns a copy of the git source with the defaulted repository name and the git repository
+// to create or update for it, the repository is nil if the git source references an existing repository
+func gitSourceRepository(functionName, namespace string, git *GitSourceData) (*GitSourceData, *serverlessv1alpha1.GitRepository, error) {
+	if git == nil || git.URL == "" {
+		return git, nil, nil
+	}
+	source := *git
+	if source.Repository == "" {
+		source.Repository = functionName
+	}
+	repository, err := buildGitRepository(source.Repository, namespace, GitRepositoryData{URL: source.URL, Auth: source.Auth})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &source, repository, nil
+}
+
+// applyGitSource makes the function use the git source
+func applyGitSource(fn *serverlessv1alpha1.Function, git GitSourceData) error {
+	if git.Repository == "" {
+		return fmt.Errorf("the git source requires the repository name or url")
+	}
+	if git.Reference == "" {
+		return fmt.Errorf("the git source requires a reference, e.g. a branch, tag or commit")
+	}
+	if git.BaseDir == "" {
+		git.BaseDir = defaultBaseDir
+	}
+
+	fn.Spec.Type = serverlessv1alpha1.SourceTypeGit
+	fn.Spec.Source = git.Repository
+	fn.Spec.Deps = ""
+	fn.Spec.Repository = serverlessv1alpha1.Repository{
+		BaseDir:   git.BaseDir,
+		Reference: git.Reference,
+	}
+	return nil
+}
+
+// upsertGitRepository creates the git repository or updates it if it already exists.
+// An existing repository is only updated if it was created by the backend, a Conflict error is returned otherwise.
+func upsertGitRepository(client gitrepository.Client, repository serverlessv1alpha1.GitRepository) (*unstructured.Unstructured, error) {
+	repositoryUnstructured, err := client.Create(repository)
+	if apierrors.IsAlreadyExists(err) {
+		return client.UpdateIf(repository, checkGitRepositoryManaged)
+	}
+	return repositoryUnstructured, err
+}
+
+// checkGitRepositoryManaged returns a Conflict error if the git repository was not created by the backend
+func checkGitRepositoryManaged(repository *unstructured.Unstructured) error {
+	if repository.GetLabels()[gitRepositoryManagedLabel] == "true" {
+		return nil
+	}
+	return apierrors.NewConflict(gitrepository.GroupVersionResource().GroupResource(), repository.GetName(),
+		fmt.Errorf("the git repository is not managed by the backend, reference it by its name instead of the url"))
+}
+
+// deleteOrphanedGitRepository deletes the git repository if the backend created it and no function of the namespace uses it
+func deleteOrphanedGitRepository(cluster *Cluster, name, namespace string) error {
+	repository, err := cluster.GitRepositoryClient.GetJson(name, namespace)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if checkGitRepositoryManaged(repository) != nil {
+		return nil
+	}
+
+	functions, err := cluster.FunctionClient.Fresh().List(namespace)
+	if err != nil {
+		return err
+	}
+	for _, fn := range functions.Items {
+		if fn.Spec.Type == serverlessv1alpha1.SourceTypeGit && fn.Spec.Source == name {
+			return nil
+		}
+	}
+
+	err = cluster.GitRepositoryClient.Delete(name, namespace)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func getAllGitRepositories(w http.ResponseWriter, r *http.Request) {
+	namespace := "default"
+	// Fetch namespace info from the query parameters
+	v := r.URL.Query()
+	if v.Get("ns") == "-A" {
+		namespace = ""
+	} else if v.Get("ns") != "" {
+		namespace = v.Get("ns")
+	}
+
+	repositories, err := clusterFromContext(r.Context()).GitRepositoryClient.List(namespace)
+	if err != nil {
+		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Convert response to bytes
+	data, err := json.Marshal(repositories)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response to user
+	_, err = w.Write(data)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
+
+func getGitRepository(w http.ResponseWriter, r *http.Request) {
+	// Fetch data from URI
+	namespace := mux.Vars(r)["ns"]
+	name := mux.Vars(r)["name"]
+
+	repositoryUnstructured, err := clusterFromContext(r.Context()).GitRepositoryClient.GetJson(name, namespace)
+	writeGitRepository(w, r, repositoryUnstructured, err, http.StatusOK)
+}
+
+func postGitRepository(w http.ResponseWriter, r *http.Request) {
+	putOrPostGitRepository(w, r, false)
+}
+
+func putGitRepository(w http.ResponseWriter, r *http.Request) {
+	putOrPostGitRepository(w, r, true)
+}
+
+// putOrPostGitRepository creates the git repository or updates the existing one
+func putOrPostGitRepository(w http.ResponseWriter, r *http.Request, update bool) {
+	// Fetch data from URI
+	namespace := mux.Vars(r)["ns"]
+	name := mux.Vars(r)["name"]
+
+	// Fetch data from request body
+	var data GitRepositoryData
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	repository, err := buildGitRepository(name, namespace, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	client := clusterFromContext(r.Context()).GitRepositoryClient
+	if update {
+		// the git repository keeps its labels, a repository which was not created by the backend is not marked as managed
+		repository.Labels = nil
+		repositoryUnstructured, err := client.Update(*repository)
+		writeGitRepository(w, r, repositoryUnstructured, err, http.StatusOK)
+		return
+	}
+	repositoryUnstructured, err := client.Create(*repository)
+	writeGitRepository(w, r, repositoryUnstructured, err, http.StatusCreated)
+}
+
+func delGitRepository(w http.ResponseWriter, r *http.Request) {
+	// Fetch data from URI
+	namespace := mux.Vars(r)["ns"]
+	name := mux.Vars(r)["name"]
+
+	err := clusterFromContext(r.Context()).GitRepositoryClient.Delete(name, namespace)
+	if apierrors.IsNotFound(err) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeGitRepository responds with the git repository or maps the error of the client to a status code
+func writeGitRepository(w http.ResponseWriter, r *http.Request, repository *unstructured.Unstructured, err error, status int) {
+	switch {
+	case apierrors.IsNotFound(err):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case apierrors.IsAlreadyExists(err), apierrors.IsConflict(err):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Convert response to bytes
+	data, err := repository.MarshalJSON()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response to user
+	w.WriteHeader(status)
+	_, err = w.Write(data)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	serverlessv1alpha1 "github.com/kyma-project/kyma/components/function-controller/pkg/apis/serverless/v1alpha1"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/function"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/gitrepository"
+)
+
+func TestBuildGitRepository(t *testing.T) {
+	tests := map[string]struct {
+		data    GitRepositoryData
+		wantErr bool
+	}{
+		"public https": {data: GitRepositoryData{URL: "https://github.com/kyma-project/examples.git"}},
+		"basic auth": {data: GitRepositoryData{
+			URL:  "https://github.com/kyma-project/examples.git",
+			Auth: &serverlessv1alpha1.RepositoryAuth{Type: serverlessv1alpha1.RepositoryAuthBasic, SecretName: "git-creds"},
+		}},
+		"ssh key": {data: GitRepositoryData{
+			URL:  "git@github.com:kyma-project/examples.git",
+			Auth: &serverlessv1alpha1.RepositoryAuth{Type: serverlessv1alpha1.RepositoryAuthSSHKey, SecretName: "git-key"},
+		}},
+		"no url":          {data: GitRepositoryData{}, wantErr: true},
+		"ssh without key": {data: GitRepositoryData{URL: "git@github.com:kyma-project/examples.git"}, wantErr: true},
+		"auth without secret": {data: GitRepositoryData{
+			URL:  "https://github.com/kyma-project/examples.git",
+			Auth: &serverlessv1alpha1.RepositoryAuth{Type: serverlessv1alpha1.RepositoryAuthBasic},
+		}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		repository, err := buildGitRepository("examples", "default", tc.data)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if err == nil && (repository.Name != "examples" || repository.Spec.URL != tc.data.URL) {
+			t.Fatalf("%s: unexpected repository: %+v", name, repository)
+		}
+	}
+}
+
+func TestGitSourceRepository(t *testing.T) {
+	git := &GitSourceData{URL: "https://github.com/kyma-project/examples.git", Reference: "main"}
+	source, repository, err := gitSourceRepository("orders", "default", git)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.Repository != "orders" || repository.Name != "orders" {
+		t.Fatalf("expected the repository name to default to the function, got %+v, %+v", source, repository)
+	}
+	if git.Repository != "" {
+		t.Fatalf("expected the given git source not to be modified, got: %+v", git)
+	}
+}
+
+func TestPostGitFunction(t *testing.T) {
+	client := newFakeDynamicClient()
+	cluster := &Cluster{
+		FunctionClient:      function.NewClient(client),
+		GitRepositoryClient: gitrepository.NewClient(client),
+	}
+
+	post := func(name, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/default/funcs/"+name, strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"ns": "default", "name": name})
+		req = req.WithContext(context.WithValue(req.Context(), clusterContextKey{}, cluster))
+		rec := httptest.NewRecorder()
+		postFunction(rec, req)
+		return rec
+	}
+
+	rec := post("orders", `{"runtime": "nodejs16", "git": {"url": "https://github.com/kyma-project/examples.git", "reference": "main", "baseDir": "/orders"}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected the function to be created, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	repository, err := cluster.GitRepositoryClient.Get("orders", "default")
+	if err != nil || repository.Spec.URL != "https://github.com/kyma-project/examples.git" {
+		t.Fatalf("expected the git repository to be created, got %+v, %v", repository, err)
+	}
+	fn, err := cluster.FunctionClient.GetFn("orders", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fn.Spec.Type != serverlessv1alpha1.SourceTypeGit || fn.Spec.Source != "orders" ||
+		fn.Spec.Reference != "main" || fn.Spec.BaseDir != "/orders" || fn.Spec.Deps != "" {
+		t.Fatalf("expected a git function, got: %+v", fn.Spec)
+	}
+
+	// a second function uses the existing repository
+	rec = post("payments", `{"git": {"repository": "orders", "reference": "v1.0.0"}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected the function to be created, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if fn, err := cluster.FunctionClient.GetFn("payments", "default"); err != nil || fn.Spec.Source != "orders" || fn.Spec.BaseDir != "/" {
+		t.Fatalf("expected the function to reference the repository, got %+v, %v", fn, err)
+	}
+
+	if rec := post("invalid", `{"git": {"url": "https://github.com/kyma-project/examples.git"}}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a git source without reference, got %d", rec.Code)
+	}
+	if _, err := cluster.GitRepositoryClient.Get("invalid", "default"); err == nil {
+		t.Fatal("expected no git repository to be created for an invalid function")
+	}
+
+	// an existing function does not touch the git repository
+	if rec := post("orders", `{"git": {"url": "https://github.com/team/other.git", "reference": "main"}}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for an existing function, got %d", rec.Code)
+	}
+	if repository, err := cluster.GitRepositoryClient.Get("orders", "default"); err != nil || repository.Spec.URL != "https://github.com/kyma-project/examples.git" {
+		t.Fatalf("expected the git repository not to be updated for an existing function, got %+v, %v", repository, err)
+	}
+
+	// a git repository which was not created by the backend is not overwritten
+	foreign := serverlessv1alpha1.GitRepository{Spec: serverlessv1alpha1.GitRepositorySpec{URL: "https://github.com/team/private.git"}}
+	foreign.Name = "private"
+	foreign.Namespace = "default"
+	if _, err := cluster.GitRepositoryClient.Create(foreign); err != nil {
+		t.Fatal(err)
+	}
+	if rec := post("private", `{"git": {"url": "https://github.com/kyma-project/examples.git", "reference": "main"}}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a git repository which is not managed by the backend, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if repository, err := cluster.GitRepositoryClient.Get("private", "default"); err != nil || repository.Spec.URL != foreign.Spec.URL {
+		t.Fatalf("expected the git repository not to be updated, got %+v, %v", repository, err)
+	}
+	if _, err := cluster.FunctionClient.GetFn("private", "default"); err == nil {
+		t.Fatal("expected the function to be removed if its git repository can not be updated")
+	}
+	// it can still be referenced by its name
+	if rec := post("private", `{"git": {"repository": "private", "reference": "main"}}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected the function to be created, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestPutGitRepositoryKeepsLabels(t *testing.T) {
+	client := newFakeDynamicClient()
+	cluster := &Cluster{GitRepositoryClient: gitrepository.NewClient(client)}
+
+	foreign := serverlessv1alpha1.GitRepository{Spec: serverlessv1alpha1.GitRepositorySpec{URL: "https://github.com/team/private.git"}}
+	foreign.Name = "private"
+	foreign.Namespace = "default"
+	foreign.Labels = map[string]string{"team": "tunas"}
+	if _, err := cluster.GitRepositoryClient.Create(foreign); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/default/gitrepos/private", strings.NewReader(`{"url": "https://github.com/team/renamed.git"}`))
+	req = mux.SetURLVars(req, map[string]string{"ns": "default", "name": "private"})
+	req = req.WithContext(context.WithValue(req.Context(), clusterContextKey{}, cluster))
+	rec := httptest.NewRecorder()
+	putGitRepository(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the git repository to be updated, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	repository, err := cluster.GitRepositoryClient.Get("private", "default")
+	if err != nil || repository.Spec.URL != "https://github.com/team/renamed.git" {
+		t.Fatalf("expected the url to be updated, got %+v, %v", repository, err)
+	}
+	if repository.Labels["team"] != "tunas" || repository.Labels[gitRepositoryManagedLabel] != "" {
+		t.Fatalf("expected the labels to be kept and the repository not to be marked as managed, got: %v", repository.Labels)
+	}
+}
+
+func TestPutGitFunction(t *testing.T) {
+	client := newFakeDynamicClient()
+	cluster := &Cluster{
+		FunctionClient:      function.NewClient(client),
+		GitRepositoryClient: gitrepository.NewClient(client),
+	}
+
+	request := func(method, name, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/default/funcs/"+name, strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"ns": "default", "name": name})
+		req = req.WithContext(context.WithValue(req.Context(), clusterContextKey{}, cluster))
+		rec := httptest.NewRecorder()
+		if method == http.MethodPost {
+			postFunction(rec, req)
+		} else {
+			putFunction(rec, req)
+		}
+		return rec
+	}
+
+	for _, name := range []string{"orders", "invoices"} {
+		body := `{"git": {"url": "https://github.com/kyma-project/examples.git", "reference": "main"}}`
+		if rec := request(http.MethodPost, name, body); rec.Code != http.StatusCreated {
+			t.Fatalf("expected the function to be created, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	if rec := request(http.MethodPost, "payments", `{"git": {"repository": "orders", "reference": "main"}}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected the function to be created, got %d: %s", rec.Code, rec.Body.String())
+	}
+	foreign := serverlessv1alpha1.GitRepository{Spec: serverlessv1alpha1.GitRepositorySpec{URL: "https://github.com/team/private.git"}}
+	foreign.Name = "private"
+	foreign.Namespace = "default"
+	if _, err := cluster.GitRepositoryClient.Create(foreign); err != nil {
+		t.Fatal(err)
+	}
+
+	// the function is restored if its git repository can not be updated
+	if rec := request(http.MethodPut, "invoices", `{"git": {"url": "https://github.com/team/other.git", "repository": "private", "reference": "v1"}}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a git repository which is not managed by the backend, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if fn, err := cluster.FunctionClient.GetFn("invoices", "default"); err != nil || fn.Spec.Source != "invoices" || fn.Spec.Reference != "main" {
+		t.Fatalf("expected the function to be restored, got %+v, %v", fn, err)
+	}
+
+	// the git repository is removed with the last function which uses it
+	if rec := request(http.MethodPut, "invoices", `{"source": "module.exports = {}"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected the function to be updated, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := cluster.GitRepositoryClient.Get("invoices", "default"); err == nil {
+		t.Fatal("expected the unused git repository to be removed")
+	}
+	if rec := request(http.MethodPut, "orders", `{"source": "module.exports = {}"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected the function to be updated, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := cluster.GitRepositoryClient.Get("orders", "default"); err != nil {
+		t.Fatalf("expected the git repository used by another function to be kept, got %v", err)
+	}
+
+	// a git repository which was not created by the backend is kept
+	if rec := request(http.MethodPut, "payments", `{"git": {"repository": "private", "reference": "main"}}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected the function to be updated, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := request(http.MethodPut, "payments", `{"source": "module.exports = {}"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected the function to be updated, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := cluster.GitRepositoryClient.Get("private", "default"); err != nil {
+		t.Fatalf("expected the git repository which is not managed by the backend to be kept, got %v", err)
+	}
+	if _, err := cluster.GitRepositoryClient.Get("orders", "default"); err == nil {
+		t.Fatal("expected the unused git repository to be removed")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// inClusterClientset returns a clientset for the cluster the backend runs in
+func inClusterClientset() (kubernetes.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// clusterUID identifies a cluster by the UID of its kube-system namespace
+func clusterUID(ctx context.Context, clientset kubernetes.Interface) (string, error) {
+	namespace, err := clientset.CoreV1().Namespaces().Get(ctx, metav1.NamespaceSystem, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return string(namespace.UID), nil
+}
+
+var (
+	ownClusterOnce sync.Once
+	ownClusterID   string
+)
+
+// ownClusterUID returns the UID of the cluster the backend runs in, it is empty outside of a cluster.
+// It is a variable, so that the tests can run the backend "inside" a fake cluster.
+var ownClusterUID = func() string {
+	ownClusterOnce.Do(func() {
+		clientset, err := inClusterClientset()
+		if err != nil {
+			return
+		}
+		ownClusterID, err = clusterUID(context.Background(), clientset)
+		if err != nil {
+			log.Printf("failed to identify the cluster of the backend: %v", err)
+		}
+	})
+	return ownClusterID
+}
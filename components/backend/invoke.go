@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/forwarder"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// functionServicePort is the port of the service of a function, functionPodPort the port the function listens on
+	functionServicePort = 80
+	functionPodPort     = 8080
+
+	defaultInvokeTimeout = 30 * time.Second
+	maxInvokeTimeout     = 5 * time.Minute
+	// maxInvokeResponseSize is the size of the response body returned to the client, a longer body is truncated
+	maxInvokeResponseSize = 1 << 20
+)
+
+// routes the function is reached through
+const (
+	InvokeViaDNS         = "dns"
+	InvokeViaPortForward = "port-forward"
+)
+
+// InvokeData is the request body to invoke a function, all fields are optional
+type InvokeData struct {
+	Method  string            `json:"method,omitempty"` // Method defaults to POST
+	Path    string            `json:"path,omitempty"`   // Path defaults to /
+	Headers map[string]string `json:"headers,omitempty"`
+	// Data is the body, a JSON string is sent as plain text and any other JSON value as application/json
+	Data json.RawMessage `json:"data,omitempty"`
+	// CloudEvent sends the data as binary-mode CloudEvent
+	CloudEvent     *InvokeCloudEvent `json:"cloudEvent,omitempty"`
+	TimeoutSeconds int               `json:"timeoutSeconds,omitempty"` // TimeoutSeconds defaults to 30, at most 300
+}
+
+// InvokeCloudEvent are the attributes of the CloudEvent sent to a function
+type InvokeCloudEvent struct {
+	ID          string            `json:"id,omitempty"` // ID is generated if it is empty
+	Type        string            `json:"type"`
+	Source      string            `json:"source"`
+	SpecVersion string            `json:"specversion,omitempty"` // SpecVersion defaults to 1.0
+	Extensions  map[string]string `json:"extensions,omitempty"`
+}
+
+// InvokeResult is the response of the function
+type InvokeResult struct {
+	Status    int         `json:"status"`
+	Headers   http.Header `json:"headers"`
+	Body      string      `json:"body"`
+	Truncated bool        `json:"truncated,omitempty"` // Truncated is true if the body exceeds 1 MiB
+	LatencyMs int64       `json:"latencyMs"`
+	Via       string      `json:"via"` // Via is dns inside the cluster, otherwise port-forward
+}
+
+// newInvokeRequest returns the request to the function at the base URL
+func newInvokeRequest(ctx context.Context, baseURL string, data InvokeData) (*http.Request, error) {
+	if data.Method == "" {
+		data.Method = http.MethodPost
+	}
+	if !strings.HasPrefix(data.Path, "/") {
+		data.Path = "/" + data.Path
+	}
+
+	var body io.Reader
+	contentType := ""
+	if len(data.Data) > 0 && string(data.Data) != "null" {
+		var text string
+		if err := json.Unmarshal(data.Data, &text); err == nil {
+			body, contentType = strings.NewReader(text), "text/plain"
+		} else {
+			body, contentType = bytes.NewReader(data.Data), "application/json"
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(data.Method), baseURL+data.Path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if event := data.CloudEvent; event != nil {
+		if event.Type == "" || event.Source == "" {
+			return nil, errors.New("the CloudEvent requires a type and a source")
+		}
+		if event.ID == "" {
+			event.ID = uuid.NewString()
+		}
+		if event.SpecVersion == "" {
+			event.SpecVersion = "1.0"
+		}
+		req.Header.Set("ce-specversion", event.SpecVersion)
+		req.Header.Set("ce-id", event.ID)
+		req.Header.Set("ce-type", event.Type)
+		req.Header.Set("ce-source", event.Source)
+		req.Header.Set("ce-time", time.Now().UTC().Format(time.RFC3339Nano))
+		for name, value := range event.Extensions {
+			req.Header.Set("ce-"+strings.ToLower(name), value)
+		}
+	}
+
+	// the given headers take precedence, e.g. to send a different content type
+	for name, value := range data.Headers {
+		req.Header.Set(name, value)
+	}
+	return req, nil
+}
+
+// invokeFunction sends the request to the function at the base URL and returns its response
+func invokeFunction(ctx context.Context, baseURL string, data InvokeData) (*InvokeResult, error) {
+	req, err := newInvokeRequest(ctx, baseURL, data)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxInvokeResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	result := &InvokeResult{
+		Status:    response.StatusCode,
+		Headers:   response.Header,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if len(body) > maxInvokeResponseSize {
+		body, result.Truncated = body[:maxInvokeResponseSize], true
+	}
+	result.Body = string(body)
+	return result, nil
+}
+
+// functionEndpoint returns the base URL of the function and how it is reached, release must be called once the
+// function was invoked. The service is reached by DNS inside the cluster, otherwise by a port-forward to a pod.
+func functionEndpoint(ctx context.Context, cluster *Cluster, name, namespace string) (baseURL, via string, release func(), err error) {
+	if cluster.InCluster(ctx) {
+		return fmt.Sprintf("http://%s.%s.svc.cluster.local:%d", name, namespace, functionServicePort), InvokeViaDNS, func() {}, nil
+	}
+
+	options := []*forwarder.Option{{
+		// the local port is chosen by the OS, so that concurrent invocations do not collide
+		LocalPort:   0,
+		RemotePort:  functionPodPort,
+		ServiceName: name,
+		Namespace:   namespace,
+		// the invocations are spread over the ready pods of the function
+		Selection: forwarder.SelectRoundRobin,
+	}}
+	result, err := forwarder.Forwarders(ctx, options, cluster.RestConfig)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	baseURL, err = forwardedURL(ctx, result)
+	if err != nil {
+		result.Close()
+		return "", "", nil, err
+	}
+	return baseURL, InvokeViaPortForward, result.Close, nil
+}
+
+func postFunctionInvoke(w http.ResponseWriter, r *http.Request) {
+	// Fetch data from URI
+	namespace := mux.Vars(r)["ns"]
+	name := mux.Vars(r)["name"]
+
+	// Fetch data from request body, an empty body sends an empty POST request
+	var data InvokeData
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	timeout := defaultInvokeTimeout
+	if data.TimeoutSeconds < 0 || time.Duration(data.TimeoutSeconds)*time.Second > maxInvokeTimeout {
+		http.Error(w, fmt.Sprintf("timeoutSeconds must be between 0 and %d", int(maxInvokeTimeout.Seconds())), http.StatusBadRequest)
+		return
+	} else if data.TimeoutSeconds > 0 {
+		timeout = time.Duration(data.TimeoutSeconds) * time.Second
+	}
+	// the request is validated before the function is reached
+	if _, err := newInvokeRequest(r.Context(), "http://function", data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cluster := clusterFromContext(r.Context())
+	if _, err := cluster.FunctionClient.GetFn(name, namespace); err != nil {
+		status := http.StatusBadRequest
+		if apierrors.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	baseURL, via, release, err := functionEndpoint(ctx, cluster, name, namespace)
+	if err != nil {
+		log.Printf("%s %s failed to reach the function: %v", r.Method, r.RequestURI, err)
+		http.Error(w, fmt.Sprintf("failed to reach the function: %v", err), upstreamErrorStatus(err))
+		return
+	}
+	defer release()
+
+	result, err := invokeFunction(ctx, baseURL, data)
+	if err != nil {
+		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+		http.Error(w, fmt.Sprintf("failed to invoke the function: %v", err), upstreamErrorStatus(err))
+		return
+	}
+	result.Via = via
+
+	// Convert response to bytes
+	response, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response to user
+	_, err = w.Write(response)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
+
+// upstreamErrorStatus maps an error reaching an upstream service to 504 on timeouts, otherwise to 502
+func upstreamErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
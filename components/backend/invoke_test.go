@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/function"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestInvokeFunction(t *testing.T) {
+	var received *http.Request
+	var receivedBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received, receivedBody = r, string(body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("Hello World!"))
+	}))
+	defer server.Close()
+
+	result, err := invokeFunction(context.Background(), server.URL, InvokeData{
+		Path: "orders",
+		Data: []byte(`{"orderId": 42}`),
+		CloudEvent: &InvokeCloudEvent{
+			Type:       "sap.kyma.custom.shop.order.created.v1",
+			Source:     "shop",
+			Extensions: map[string]string{"traceparent": "00-abc-def-01"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to invoke function: %v", err)
+	}
+	if result.Status != http.StatusAccepted || result.Body != "Hello World!" || result.Headers.Get("Content-Type") != "text/plain" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	if received.Method != http.MethodPost || received.URL.Path != "/orders" || receivedBody != `{"orderId": 42}` {
+		t.Fatalf("unexpected request: %s %s %s", received.Method, received.URL.Path, receivedBody)
+	}
+	for header, want := range map[string]string{
+		"Content-Type":   "application/json",
+		"Ce-Specversion": "1.0",
+		"Ce-Type":        "sap.kyma.custom.shop.order.created.v1",
+		"Ce-Source":      "shop",
+		"Ce-Traceparent": "00-abc-def-01",
+	} {
+		if got := received.Header.Get(header); got != want {
+			t.Fatalf("expected header %s %q, got %q", header, want, got)
+		}
+	}
+	if received.Header.Get("Ce-Id") == "" || received.Header.Get("Ce-Time") == "" {
+		t.Fatalf("expected a generated id and time, got: %v", received.Header)
+	}
+
+	if _, err := invokeFunction(context.Background(), server.URL, InvokeData{Method: "GET", Data: []byte(`"ping"`)}); err != nil {
+		t.Fatal(err)
+	}
+	if received.Method != http.MethodGet || receivedBody != "ping" || received.Header.Get("Content-Type") != "text/plain" {
+		t.Fatalf("expected a plain text GET request, got: %s %s %q", received.Method, received.Header.Get("Content-Type"), receivedBody)
+	}
+	if received.Header.Get("Ce-Id") != "" {
+		t.Fatal("expected no CloudEvent headers for a plain HTTP request")
+	}
+}
+
+func TestPostFunctionInvokeValidation(t *testing.T) {
+	cluster := &Cluster{FunctionClient: function.NewClient(newFakeDynamicClient())}
+
+	invoke := func(name, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/default/funcs/"+name+"/invoke", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"ns": "default", "name": name})
+		req = req.WithContext(context.WithValue(req.Context(), clusterContextKey{}, cluster))
+		rec := httptest.NewRecorder()
+		postFunctionInvoke(rec, req)
+		return rec
+	}
+
+	if rec := invoke("orders", `{"cloudEvent": {"type": "order.created"}}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a CloudEvent without source, got %d", rec.Code)
+	}
+	if rec := invoke("orders", `{"timeoutSeconds": 3600}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a too long timeout, got %d", rec.Code)
+	}
+	if rec := invoke("orders", ``); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing function, got %d", rec.Code)
+	}
+}
+
+func TestClusterInCluster(t *testing.T) {
+	defer func(original func() string) { ownClusterUID = original }(ownClusterUID)
+	ownClusterUID = func() string { return "own" }
+
+	newCluster := func(uid string) *Cluster {
+		return &Cluster{Clientset: fake.NewSimpleClientset(&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: metav1.NamespaceSystem, UID: types.UID(uid)},
+		})}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if !newCluster("own").InCluster(ctx) {
+		t.Fatal("expected the backend to run inside the cluster with the same kube-system UID")
+	}
+	if newCluster("other").InCluster(ctx) {
+		t.Fatal("expected the backend to run outside of another cluster")
+	}
+
+	// a failed lookup is not repeated on every call
+	forbidden := newCluster("own")
+	clientset := forbidden.Clientset.(*fake.Clientset)
+	clientset.PrependReactor("get", "namespaces", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(corev1.Resource("namespaces"), metav1.NamespaceSystem, errors.New("forbidden"))
+	})
+	if forbidden.InCluster(ctx) || forbidden.InCluster(ctx) {
+		t.Fatal("expected the backend to run outside of a cluster which can not be identified")
+	}
+	if lookups := len(clientset.Actions()); lookups != 1 {
+		t.Fatalf("expected the failed lookup to be cached, got %d lookups", lookups)
+	}
+	forbidden.locationRetry = time.Now()
+	forbidden.InCluster(ctx)
+	if lookups := len(clientset.Actions()); lookups != 2 {
+		t.Fatalf("expected the lookup to be retried, got %d lookups", lookups)
+	}
+
+	ownClusterUID = func() string { return "" }
+	if newCluster("").InCluster(ctx) {
+		t.Fatal("expected the backend to run outside of every cluster without an own cluster")
+	}
+}
@@ -0,0 +1,535 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/auth"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/forwarder"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// reachableTimeout limits how long the reachability check of a cluster may take
+	reachableTimeout = 5 * time.Second
+	// kymaVersionLabel is set by the Kyma reconciler on the deployments it manages
+	kymaVersionLabel = "reconciler.kyma-project.io/origin-version"
+	// kymaManagedBySelector selects the deployments managed by the Kyma reconciler
+	kymaManagedBySelector = "reconciler.kyma-project.io/managed-by=reconciler"
+)
+
+// KubeconfigInfo describes a registered kubeconfig without exposing its secrets
+type KubeconfigInfo struct {
+	Name           string `json:"name"`
+	Server         string `json:"server"`
+	CurrentContext string `json:"currentContext"`
+	AuthType       string `json:"authType"`
+	Reachable      bool   `json:"reachable"`
+	ServerVersion  string `json:"serverVersion,omitempty"`
+	KymaVersion    string `json:"kymaVersion,omitempty"`
+	// EventTypePrefix is the prefix of the event types built from the app, event and version fields
+	EventTypePrefix         string `json:"eventTypePrefix,omitempty"`
+	EventTypePrefixOverride bool   `json:"eventTypePrefixOverride"`
+	// EPPTransport is how the eventing publisher proxy is reached: dns, port-forward or service-proxy
+	EPPTransport         EPPTransportKind `json:"eppTransport,omitempty"`
+	EPPTransportOverride bool             `json:"eppTransportOverride"`
+	// EPPForwarderState is the state of the port-forward to EPP: connecting, ready or failed
+	EPPForwarderState forwarder.State `json:"eppForwarderState,omitempty"`
+	EPPForwarderError string          `json:"eppForwarderError,omitempty"` // EPPForwarderError is the last error of the port-forward
+	Error             string          `json:"error,omitempty"`
+}
+
+// KubeconfigContext describes a context of a registered kubeconfig
+type KubeconfigContext struct {
+	Name      string `json:"name"`
+	Cluster   string `json:"cluster"`
+	User      string `json:"user"`
+	Namespace string `json:"namespace,omitempty"`
+	Server    string `json:"server,omitempty"`
+	Current   bool   `json:"current"` // Current is true for the context used by the registered cluster
+}
+
+// invalidClusterNameChars matches the characters which are replaced in cluster names derived from contexts
+var invalidClusterNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// KubeconfigPatch is the request body to rename a kubeconfig or to override its event type prefix or EPP transport
+type KubeconfigPatch struct {
+	Name            string  `json:"name,omitempty"`
+	EventTypePrefix *string `json:"eventTypePrefix,omitempty"` // an empty prefix removes the override
+	EPPTransport    *string `json:"eppTransport,omitempty"`    // an empty transport removes the override
+}
+
+func getKubeconfigs(w http.ResponseWriter, r *http.Request) {
+	data, err := json.Marshal(clusters.Names(auth.UserFromContext(r.Context())))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Return response to user
+	_, err = w.Write(data)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func addKubeconfig(w http.ResponseWriter, r *http.Request) {
+	// Fetch data from URI
+	name := mux.Vars(r)["name"]
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	kc := string(data)
+	if kc == "" {
+		log.Printf("%s %s Invalid req body", r.Method, r.RequestURI)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Fetch the contexts to register from the query parameters, one cluster is registered per context
+	v := r.URL.Query()
+	prefix := v.Get("eventTypePrefix")
+	if prefix != "" {
+		if err := validateEventTypePrefix(prefix); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	transport := EPPTransportKind(v.Get("eppTransport"))
+	if transport != "" {
+		if _, err := parseEPPTransportKind(string(transport)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	contexts := []string{v.Get("context")}
+	names := []string{name}
+	if v.Get("allContexts") == "true" {
+		config, err := clientcmd.Load(data)
+		if err != nil {
+			writeValidationError(w, r, newValidationError(StepParse, err))
+			return
+		}
+		contexts = contextNames(config)
+		names = make([]string, 0, len(contexts))
+		for _, context := range contexts {
+			names = append(names, contextClusterName(name, context))
+		}
+	}
+
+	// the clusters are registered only after all checks passed,
+	// so a broken kubeconfig never replaces a working one
+	newClusters := make([]*Cluster, 0, len(contexts))
+	for i, context := range contexts {
+		cluster, err := prepareCluster(r.Context(), auth.UserFromContext(r.Context()), names[i], kc, context, transport)
+		if err != nil {
+			for _, c := range newClusters {
+				c.Close()
+			}
+			writeValidationError(w, r, err)
+			return
+		}
+		cluster.SetEventTypePrefixOverride(prefix)
+		newClusters = append(newClusters, cluster)
+	}
+
+	for _, cluster := range newClusters {
+		if err := persistCluster(cluster); err != nil {
+			log.Printf("%s %s failed to persist cluster %s: %v", r.Method, r.RequestURI, cluster.Name, err)
+			for _, c := range newClusters {
+				c.Close()
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	for _, cluster := range newClusters {
+		clusters.Replace(cluster)
+	}
+
+	// Convert response to bytes
+	response, err := json.Marshal(names)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response to user
+	_, err = w.Write(response)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+	log.Printf("kubeconfig was set for clusters %v", names)
+}
+
+// prepareCluster creates the cluster for the kubeconfig context, validates it
+// and connects the transport to EPP, e.g. starts the port-forward, without registering the cluster
+func prepareCluster(ctx context.Context, owner, name, kubeconfig, context string, transport EPPTransportKind) (*Cluster, error) {
+	cluster, err := NewCluster(owner, name, kubeconfig, context)
+	if err != nil {
+		return nil, withContext(err, context)
+	}
+
+	if err := validateCluster(cluster); err != nil {
+		return nil, withContext(err, cluster.Context)
+	}
+
+	// connect the transport to EPP
+	if err := checkEPPTransportOverride(ctx, cluster, transport); err != nil {
+		return nil, withContext(newValidationError(StepPortForward, err), cluster.Context)
+	}
+	cluster.SetEPPTransportOverride(transport)
+	if err := cluster.EPPTransport(ctx).Connect(ctx); err != nil {
+		return nil, withContext(newValidationError(StepPortForward, err), cluster.Context)
+	}
+
+	return cluster, nil
+}
+
+func getKubeconfigContexts(w http.ResponseWriter, r *http.Request) {
+	// Fetch data from URI
+	name := mux.Vars(r)["name"]
+
+	cluster, ok := clusters.Get(auth.UserFromContext(r.Context()), name)
+	if !ok {
+		http.Error(w, ErrClusterNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	config, err := clientcmd.Load([]byte(cluster.Kubeconfig))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	contexts := make([]KubeconfigContext, 0, len(config.Contexts))
+	for _, contextName := range contextNames(config) {
+		context := config.Contexts[contextName]
+		kubeconfigContext := KubeconfigContext{
+			Name:      contextName,
+			Cluster:   context.Cluster,
+			User:      context.AuthInfo,
+			Namespace: context.Namespace,
+			Current:   contextName == cluster.Context,
+		}
+		if c, ok := config.Clusters[context.Cluster]; ok {
+			kubeconfigContext.Server = c.Server
+		}
+		contexts = append(contexts, kubeconfigContext)
+	}
+
+	data, err := json.Marshal(contexts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response to user
+	_, err = w.Write(data)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
+
+// contextNames returns the sorted context names of the kubeconfig
+func contextNames(config *clientcmdapi.Config) []string {
+	names := make([]string, 0, len(config.Contexts))
+	for name := range config.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// contextClusterName returns the cluster name for a kubeconfig context,
+// characters which are not allowed in a route segment are replaced with a dash
+func contextClusterName(name, context string) string {
+	return name + "-" + invalidClusterNameChars.ReplaceAllString(context, "-")
+}
+
+func getKubeconfig(w http.ResponseWriter, r *http.Request) {
+	// Fetch data from URI
+	name := mux.Vars(r)["name"]
+
+	cluster, ok := clusters.Get(auth.UserFromContext(r.Context()), name)
+	if !ok {
+		http.Error(w, ErrClusterNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	info, err := kubeconfigInfo(r.Context(), cluster)
+	if err != nil {
+		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response to user
+	_, err = w.Write(data)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
+
+func patchKubeconfig(w http.ResponseWriter, r *http.Request) {
+	// Fetch data from URI
+	name := mux.Vars(r)["name"]
+
+	// Fetch data from request body
+	var patch KubeconfigPatch
+	err := json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if patch.EventTypePrefix != nil && *patch.EventTypePrefix != "" {
+		if err := validateEventTypePrefix(*patch.EventTypePrefix); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if patch.EPPTransport != nil && *patch.EPPTransport != "" {
+		if _, err := parseEPPTransportKind(*patch.EPPTransport); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	user := auth.UserFromContext(r.Context())
+	cluster, ok := clusters.Get(user, name)
+	if !ok {
+		http.Error(w, ErrClusterNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if patch.EPPTransport != nil {
+		if err := checkEPPTransportOverride(r.Context(), cluster, EPPTransportKind(*patch.EPPTransport)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// The patched cluster is stored first, the registered cluster is only changed once it is stored
+	record := clusterRecord(cluster)
+	renamed := patch.Name != "" && patch.Name != name
+	if renamed {
+		if _, ok := clusters.Get(user, patch.Name); ok {
+			http.Error(w, fmt.Errorf("%w: %s", ErrClusterExists, patch.Name).Error(), http.StatusConflict)
+			return
+		}
+		record.Name = patch.Name
+	}
+	if patch.EventTypePrefix != nil {
+		record.EventTypePrefix = *patch.EventTypePrefix
+	}
+	if patch.EPPTransport != nil {
+		record.EPPTransport = *patch.EPPTransport
+	}
+
+	if err := persistRecord(record); err != nil {
+		log.Printf("%s %s failed to persist cluster %s: %v", r.Method, r.RequestURI, record.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if renamed {
+		if err := forgetCluster(user, name); err != nil {
+			log.Printf("%s %s failed to delete stored cluster %s: %v", r.Method, r.RequestURI, name, err)
+			// the cluster stays stored by its old name only
+			if err := forgetCluster(user, record.Name); err != nil {
+				log.Printf("%s %s failed to delete stored cluster %s: %v", r.Method, r.RequestURI, record.Name, err)
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		cluster, err = clusters.Rename(user, name, patch.Name)
+		if err != nil {
+			// the cluster was removed or the name was taken in the meantime, the stored clusters are restored
+			restorePatchedCluster(user, name, patch.Name)
+		}
+		switch {
+		case errors.Is(err, ErrClusterNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, ErrClusterExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("kubeconfig %s was renamed to %s", name, cluster.Name)
+	}
+	if patch.EventTypePrefix != nil {
+		cluster.SetEventTypePrefixOverride(*patch.EventTypePrefix)
+	}
+	if patch.EPPTransport != nil {
+		cluster.SetEPPTransportOverride(EPPTransportKind(*patch.EPPTransport))
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// restorePatchedCluster stores the registered clusters of both names again after a failed rename,
+// a name which is not registered is deleted from the store
+func restorePatchedCluster(owner string, names ...string) {
+	for _, name := range names {
+		var err error
+		if cluster, ok := clusters.Get(owner, name); ok {
+			err = persistCluster(cluster)
+		} else {
+			err = forgetCluster(owner, name)
+		}
+		if err != nil {
+			log.Printf("failed to restore stored cluster %s: %v", name, err)
+		}
+	}
+}
+
+func delKubeconfig(w http.ResponseWriter, r *http.Request) {
+	// Fetch data from URI
+	name := mux.Vars(r)["name"]
+
+	// Removing the cluster also closes its port-forward to EPP
+	user := auth.UserFromContext(r.Context())
+	err := clusters.Remove(user, name)
+	if errors.Is(err, ErrClusterNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := forgetCluster(user, name); err != nil {
+		log.Printf("%s %s failed to delete stored cluster %s: %v", r.Method, r.RequestURI, name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	log.Printf("kubeconfig %s was deleted", name)
+}
+
+// kubeconfigInfo collects the metadata of the cluster, the reachability is checked with a short timeout
+func kubeconfigInfo(ctx context.Context, cluster *Cluster) (*KubeconfigInfo, error) {
+	config, err := clientcmd.Load([]byte(cluster.Kubeconfig))
+	if err != nil {
+		return nil, err
+	}
+
+	info := &KubeconfigInfo{
+		Name:           cluster.Name,
+		Server:         cluster.RestConfig.Host,
+		CurrentContext: cluster.Context,
+	}
+	if kubeContext, ok := config.Contexts[cluster.Context]; ok {
+		info.AuthType = authType(config.AuthInfos[kubeContext.AuthInfo])
+	}
+
+	restConfig := rest.CopyConfig(cluster.RestConfig)
+	restConfig.Timeout = reachableTimeout
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	serverVersion, err := discoveryClient.ServerVersion()
+	if err != nil {
+		info.Error = err.Error()
+		return info, nil
+	}
+	info.Reachable = true
+	info.ServerVersion = serverVersion.GitVersion
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	info.KymaVersion, err = kymaVersion(ctx, clientset)
+	if err != nil {
+		info.Error = err.Error()
+	}
+
+	info.EPPTransportOverride = cluster.EPPTransportOverride() != ""
+	info.EPPTransport = cluster.EPPTransport(ctx).Kind()
+	if result := cluster.Forwarder(); result != nil && result.State != nil {
+		state, err := result.State()
+		info.EPPForwarderState = state
+		if err != nil {
+			info.EPPForwarderError = err.Error()
+		}
+	}
+
+	info.EventTypePrefixOverride = cluster.EventTypePrefixOverride() != ""
+	info.EventTypePrefix, err = cluster.EventTypePrefix(ctx)
+	if err != nil && info.Error == "" {
+		info.Error = err.Error()
+	}
+
+	return info, nil
+}
+
+// kymaVersion returns the Kyma version from the labels of the deployments managed by the Kyma reconciler,
+// an empty version is returned if Kyma is not installed by the reconciler
+func kymaVersion(ctx context.Context, clientset kubernetes.Interface) (string, error) {
+	deployments, err := clientset.AppsV1().Deployments("kyma-system").List(ctx, metav1.ListOptions{
+		LabelSelector: kymaManagedBySelector,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, deployment := range deployments.Items {
+		if version := deployment.Labels[kymaVersionLabel]; version != "" {
+			return version, nil
+		}
+	}
+	return "", nil
+}
+
+// authType returns the kind of credentials used by the kubeconfig user
+func authType(authInfo *clientcmdapi.AuthInfo) string {
+	switch {
+	case authInfo == nil:
+		return "none"
+	case authInfo.Token != "" || authInfo.TokenFile != "":
+		return "token"
+	case len(authInfo.ClientCertificateData) > 0 || authInfo.ClientCertificate != "":
+		return "client-certificate"
+	case authInfo.Exec != nil:
+		return "exec"
+	case authInfo.AuthProvider != nil:
+		return "auth-provider"
+	case authInfo.Username != "":
+		return "basic"
+	default:
+		return "none"
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/store"
+)
+
+// failingStore is a cluster store whose saves fail
+type failingStore struct {
+	deleted []string
+}
+
+func (s *failingStore) Save(store.Record) error {
+	return errors.New("store is not writable")
+}
+
+func (s *failingStore) Delete(owner, name string) error {
+	s.deleted = append(s.deleted, name)
+	return nil
+}
+
+func (s *failingStore) List() ([]store.Record, error) {
+	return nil, nil
+}
+
+func TestPatchKubeconfigPersistsFirst(t *testing.T) {
+	defer func(original store.Store) { clusterStore = original }(clusterStore)
+	failing := &failingStore{}
+	clusterStore = failing
+
+	var closed int32
+	clusters.Replace(newTestCluster("patch-dev", &closed))
+	defer func() { _ = clusters.Remove("", "patch-dev") }()
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/kubeconfig/patch-dev",
+		strings.NewReader(`{"name": "patch-prod", "eventTypePrefix": "sap.kyma.custom", "eppTransport": "service-proxy"}`))
+	req = mux.SetURLVars(req.WithContext(context.Background()), map[string]string{"name": "patch-dev"})
+	rec := httptest.NewRecorder()
+	patchKubeconfig(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 if the cluster can not be stored, got %d: %s", rec.Code, rec.Body.String())
+	}
+	cluster, ok := clusters.Get("", "patch-dev")
+	if !ok {
+		t.Fatal("expected the cluster to keep its name")
+	}
+	if _, ok := clusters.Get("", "patch-prod"); ok {
+		t.Fatal("expected the cluster not to be renamed")
+	}
+	if cluster.EventTypePrefixOverride() != "" || cluster.EPPTransportOverride() != "" {
+		t.Fatalf("expected the overrides not to change, got %q and %q", cluster.EventTypePrefixOverride(), cluster.EPPTransportOverride())
+	}
+	if len(failing.deleted) != 0 {
+		t.Fatalf("expected no stored cluster to be deleted, deleted: %v", failing.deleted)
+	}
+}
+
+// testKubeconfig returns a kubeconfig of the API server with an inline token
+func testKubeconfig(server string) string {
+	return `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: ` + server + `
+users:
+- name: test
+  user:
+    token: test-token
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+`
+}
+
+func TestAddKubeconfigRejectsDNSOfForeignCluster(t *testing.T) {
+	defer func(original func() string) { ownClusterUID = original }(ownClusterUID)
+	ownClusterUID = func() string { return "own" }
+
+	// the fake API server has no kube-system namespace, so it is not the cluster the backend runs in
+	server := newFakeAPIServer(t, map[string][]string{
+		"eventing.kyma-project.io/v1alpha1":   {"subscriptions"},
+		"serverless.kyma-project.io/v1alpha1": {"functions", "gitrepositories"},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/kubeconfig/foreign?eppTransport=dns", strings.NewReader(testKubeconfig(server.URL)))
+	req = mux.SetURLVars(req, map[string]string{"name": "foreign"})
+	rec := httptest.NewRecorder()
+	addKubeconfig(rec, req)
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "only allowed for the cluster the backend runs in") {
+		t.Fatalf("expected 400 for the dns transport of a foreign cluster, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, ok := clusters.Get("", "foreign"); ok {
+		t.Fatal("expected the cluster not to be registered")
+	}
+}
+
+func TestPatchKubeconfigRejectsDNSOfForeignCluster(t *testing.T) {
+	var closed int32
+	// the backend runs outside of any cluster
+	clusters.Replace(newTestCluster("patch-foreign", &closed))
+	defer func() { _ = clusters.Remove("", "patch-foreign") }()
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/kubeconfig/patch-foreign", strings.NewReader(`{"eppTransport": "dns"}`))
+	req = mux.SetURLVars(req, map[string]string{"name": "patch-foreign"})
+	rec := httptest.NewRecorder()
+	patchKubeconfig(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for the dns transport of a foreign cluster, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if cluster, ok := clusters.Get("", "patch-foreign"); !ok || cluster.EPPTransportOverride() != "" {
+		t.Fatal("expected the transport override not to change")
+	}
+}
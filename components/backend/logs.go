@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/function"
+)
+
+// logBufferSize is the number of log lines buffered per log stream
+const logBufferSize = 100
+
+// logOptionsParam returns the log options of the query parameters
+// pod, container, tailLines, sinceSeconds, timestamps, previous and follow
+func logOptionsParam(v url.Values) (function.LogOptions, error) {
+	opts := function.LogOptions{
+		Pod:       v.Get("pod"),
+		Container: v.Get("container"),
+	}
+
+	var err error
+	if opts.TailLines, err = int64Param(v, "tailLines"); err != nil {
+		return opts, err
+	}
+	if opts.SinceSeconds, err = int64Param(v, "sinceSeconds"); err != nil {
+		return opts, err
+	}
+	if opts.Timestamps, err = boolParam(v, "timestamps"); err != nil {
+		return opts, err
+	}
+	if opts.Previous, err = boolParam(v, "previous"); err != nil {
+		return opts, err
+	}
+	if opts.Follow, err = boolParam(v, "follow"); err != nil {
+		return opts, err
+	}
+	if opts.Follow && opts.Previous {
+		return opts, errors.New("the logs of the previous container can not be followed")
+	}
+	return opts, nil
+}
+
+// int64Param returns the positive integer of the query parameter or nil if it is not set
+func int64Param(v url.Values, key string) (*int64, error) {
+	if v.Get(key) == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseInt(v.Get(key), 10, 64)
+	if err != nil || value < 0 {
+		return nil, fmt.Errorf("invalid %s %q, expected a positive number", key, v.Get(key))
+	}
+	return &value, nil
+}
+
+// boolParam returns the boolean of the query parameter, it is false if it is not set
+func boolParam(v url.Values, key string) (bool, error) {
+	if v.Get(key) == "" {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(v.Get(key))
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q, expected true or false", key, v.Get(key))
+	}
+	return value, nil
+}
+
+// getFunctionLogs returns the logs of all pods of the function as one text, every line is prefixed with its pod
+func getFunctionLogs(w http.ResponseWriter, r *http.Request) {
+	logHitEndpoint(r.RequestURI)
+	// Fetch data from URI
+	namespace := mux.Vars(r)["ns"]
+	name := mux.Vars(r)["name"]
+
+	opts, err := logOptionsParam(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cluster := clusterFromContext(r.Context())
+	logsData, err := cluster.FunctionClient.GetFunctionLogs(r.Context(), cluster.Clientset, name, namespace, opts)
+	if err != nil {
+		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Convert response to bytes
+	data, err := json.Marshal(mergePodLogs(logsData))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Return response to user
+	_, err = w.Write(data)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
+
+// mergePodLogs joins the logs of the pods sorted by pod name, every line is prefixed with its pod
+func mergePodLogs(logsData map[string]string) string {
+	pods := make([]string, 0, len(logsData))
+	for pod := range logsData {
+		pods = append(pods, pod)
+	}
+	sort.Strings(pods)
+
+	var merged strings.Builder
+	for _, pod := range pods {
+		for _, line := range strings.Split(strings.TrimSuffix(logsData[pod], "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			fmt.Fprintf(&merged, "[%s] %s\n", pod, line)
+		}
+	}
+	return merged.String()
+}
+
+// streamFunctionLogs streams the log lines of all pods of the function as Server-Sent Events.
+// Without follow the stream ends with an end event once the logs of all pods are sent.
+func streamFunctionLogs(w http.ResponseWriter, r *http.Request) {
+	// Fetch data from URI
+	namespace := mux.Vars(r)["ns"]
+	name := mux.Vars(r)["name"]
+
+	opts, err := logOptionsParam(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	cluster := clusterFromContext(r.Context())
+	if _, err := cluster.FunctionClient.FunctionPods(r.Context(), cluster.Clientset, name, namespace, opts.Pod); err != nil {
+		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Stream the logs of the pods, they are stopped when the client disconnects
+	lines := make(chan function.LogLine, logBufferSize)
+	streamErr := make(chan error, 1)
+	go func() {
+		streamErr <- cluster.FunctionClient.StreamFunctionLogs(r.Context(), cluster.Clientset, name, namespace, opts, lines)
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(watchHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case line := <-lines:
+			err = writeLogLine(w, line)
+		case err = <-streamErr:
+			// the pods write to the buffered channel before they return
+			for len(lines) > 0 && err == nil {
+				err = writeLogLine(w, <-lines)
+			}
+			if err == nil {
+				_, err = fmt.Fprint(w, "event: end\ndata: {}\n\n")
+			}
+			flusher.Flush()
+			if err != nil {
+				log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+			}
+			return
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err != nil {
+			log.Printf("%s %s failed to write log line: %v", r.Method, r.RequestURI, err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeLogLine writes the log line as log event, or as error event if the logs of the pod failed
+func writeLogLine(w http.ResponseWriter, line function.LogLine) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	event := "log"
+	if line.Error != "" {
+		event = "error"
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
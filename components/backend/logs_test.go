@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/function"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newFunctionPod(name, fnName string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      name,
+		Namespace: "default",
+		Labels: map[string]string{
+			"serverless.kyma-project.io/function-name": fnName,
+			"serverless.kyma-project.io/resource":      "deployment",
+		},
+	}}
+}
+
+func newLogsRequest(target string, cluster *Cluster) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req = mux.SetURLVars(req, map[string]string{"ns": "default", "name": "orders"})
+	return req.WithContext(context.WithValue(req.Context(), clusterContextKey{}, cluster))
+}
+
+func TestGetFunctionLogs(t *testing.T) {
+	cluster := &Cluster{
+		FunctionClient: function.NewClient(newFakeDynamicClient()),
+		Clientset: fake.NewSimpleClientset(
+			newFunctionPod("orders-b", "orders"), newFunctionPod("orders-a", "orders"), newFunctionPod("other", "other")),
+	}
+
+	rec := httptest.NewRecorder()
+	getFunctionLogs(rec, newLogsRequest("/api/default/funcs/orders/logs?tailLines=10", cluster))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var logs string
+	if err := json.Unmarshal(rec.Body.Bytes(), &logs); err != nil {
+		t.Fatal(err)
+	}
+	// the fake clientset returns the same logs for every pod
+	if logs != "[orders-a] fake logs\n[orders-b] fake logs\n" {
+		t.Fatalf("expected the logs of both pods, got: %q", logs)
+	}
+
+	rec = httptest.NewRecorder()
+	getFunctionLogs(rec, newLogsRequest("/api/default/funcs/orders/logs?pod=other", cluster))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a pod of another function, got %d", rec.Code)
+	}
+
+	// a function without pods, e.g. while it is built or scaled to zero, has no logs yet
+	req := newLogsRequest("/api/default/funcs/building/logs", cluster)
+	req = mux.SetURLVars(req, map[string]string{"ns": "default", "name": "building"})
+	rec = httptest.NewRecorder()
+	getFunctionLogs(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != `""` {
+		t.Fatalf("expected empty logs for a function without pods, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	getFunctionLogs(rec, newLogsRequest("/api/default/funcs/orders/logs?tailLines=-1", cluster))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid tail lines, got %d", rec.Code)
+	}
+}
+
+func TestStreamFunctionLogs(t *testing.T) {
+	cluster := &Cluster{
+		FunctionClient: function.NewClient(newFakeDynamicClient()),
+		Clientset:      fake.NewSimpleClientset(newFunctionPod("orders-a", "orders"), newFunctionPod("orders-b", "orders")),
+	}
+
+	rec := httptest.NewRecorder()
+	streamFunctionLogs(rec, newLogsRequest("/api/default/funcs/orders/logs/stream?timestamps=true", cluster))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"event: log\ndata: {\"pod\":\"orders-a\",\"line\":\"fake logs\"}\n\n",
+		"event: log\ndata: {\"pod\":\"orders-b\",\"line\":\"fake logs\"}\n\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in the stream, got: %s", want, body)
+		}
+	}
+	if !strings.HasSuffix(body, "event: end\ndata: {}\n\n") {
+		t.Fatalf("expected the stream to end with an end event, got: %s", body)
+	}
+
+	req := newLogsRequest("/api/default/funcs/building/logs/stream", cluster)
+	req = mux.SetURLVars(req, map[string]string{"ns": "default", "name": "building"})
+	rec = httptest.NewRecorder()
+	streamFunctionLogs(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "event: end\ndata: {}\n\n" {
+		t.Fatalf("expected an empty stream for a function without pods, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	streamFunctionLogs(rec, newLogsRequest("/api/default/funcs/orders/logs/stream?follow=true&previous=true", cluster))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for following previous logs, got %d", rec.Code)
+	}
+}
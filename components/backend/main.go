@@ -3,78 +3,99 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"reflect"
 
 	"github.com/gorilla/mux"
-	eventingv1alpha1 "github.com/kyma-project/kyma/components/eventing-controller/api/v1alpha1"
-	serverlessv1alpha1 "github.com/kyma-project/kyma/components/function-controller/pkg/apis/serverless/v1alpha1"
 	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/forwarder"
-	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/function"
-	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/subscription"
-	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
-type K8sResourceClients struct {
-	subscriptionClient subscription.Client
-	functionClient     function.Client
-}
-
-var K8sClients = make(map[string]*K8sResourceClients)
-var k8sClientConfigs = make(map[string]*rest.Config)
-var kubeconfigs = make(map[string]string)
-var defaultCluster = "default"
-var portForwardResult *forwarder.Result = nil
-
-type SubscriptionData struct {
-	Sink         string `json:"sink"`
-	AppName      string `json:"appName"`
-	EventName    string `json:"eventName"`
-	EventVersion string `json:"eventVersion"`
-}
+var clusters = NewClusterRegistry()
 
 func main() {
+	// Restore the clusters registered before the restart
+	var err error
+	clusterStore, err = newClusterStore()
+	if err != nil {
+		log.Fatalf("failed to create the cluster store: %v", err)
+	}
+	if err := restoreClusters(); err != nil {
+		log.Printf("failed to restore clusters: %v", err)
+	}
+	loadFunctionTemplates()
+
 	// Start the server
 	handleRequests()
 
-	if portForwardResult != nil {
-		portForwardResult.Close()
-	}
+	clusters.Close()
 }
 
 func handleRequests() {
+	authenticate, err := authMiddleware()
+	if err != nil {
+		log.Fatalf("failed to set up authentication: %v", err)
+	}
+
 	r := mux.NewRouter().StrictSlash(true)
 	r.Use(commonMiddleware)
+	r.Use(authenticate)
 
 	r.HandleFunc("/api/kubeconfig/{name}", addKubeconfig).Methods("POST")
+	r.HandleFunc("/api/kubeconfig/{name}", getKubeconfig).Methods("GET")
+	r.HandleFunc("/api/kubeconfig/{name}", patchKubeconfig).Methods("PATCH")
+	r.HandleFunc("/api/kubeconfig/{name}", delKubeconfig).Methods("DELETE")
+	r.HandleFunc("/api/kubeconfig/{name}/contexts", getKubeconfigContexts).Methods("GET")
 	r.HandleFunc("/api/kubeconfigs", getKubeconfigs).Methods("GET")
+	r.HandleFunc("/api/templates", getTemplates).Methods("GET")
+	r.HandleFunc("/api/stream-token", postStreamToken).Methods("POST")
 
-	r.HandleFunc("/api/subs", getAllSubs).Methods("GET")
-	r.HandleFunc("/api/{ns}/subs/{name}", postSub).Methods("POST")
-	r.HandleFunc("/api/{ns}/subs/{name}", getSub).Methods("GET")
-	r.HandleFunc("/api/{ns}/subs/{name}", putSub).Methods("PUT")
-	r.HandleFunc("/api/{ns}/subs/{name}", delSub).Methods("DELETE")
-
-	r.HandleFunc("/api/funcs/", getAllFunctions).Methods("GET")
-	r.HandleFunc("/api/{ns}/funcs/{name}", postFunction).Methods("POST")
-	r.HandleFunc("/api/{ns}/funcs/{name}", getFunction).Methods("GET")
-	r.HandleFunc("/api/{ns}/funcs/{name}", putFunction).Methods("PUT")
-	r.HandleFunc("/api/{ns}/funcs/{name}", delFunction).Methods("DELETE")
-	r.HandleFunc("/api/{ns}/funcs/{name}/logs", getFunctionLogs).Methods("GET")
-
-	r.HandleFunc("/api/publishEvent", publishEvent).Methods("POST")
-
-	r.HandleFunc("/api/cleaneventtypes", getAllCleanEventTypes).Methods("GET")
+	// the cluster prefixed routes must be registered first, otherwise
+	// they would be shadowed by the namespaced routes below
+	handleClusterRequests(r.PathPrefix("/api/clusters/{cluster}").Subrouter())
+	handleClusterRequests(r.PathPrefix("/api").Subrouter())
 
 	log.Printf("Server listening on port 8000 ...")
 	log.Fatal(http.ListenAndServe(":8000", r))
 }
 
+// handleClusterRequests registers the routes which operate on a single cluster.
+// The target cluster is resolved by clusterMiddleware.
+func handleClusterRequests(r *mux.Router) {
+	r.Use(clusterMiddleware)
+
+	r.HandleFunc("/subs", getAllSubs).Methods("GET")
+	r.HandleFunc("/{ns}/subs/{name}", postSub).Methods("POST")
+	r.HandleFunc("/{ns}/subs/{name}", getSub).Methods("GET")
+	r.HandleFunc("/{ns}/subs/{name}", putSub).Methods("PUT")
+	r.HandleFunc("/{ns}/subs/{name}", delSub).Methods("DELETE")
+	r.HandleFunc("/{ns}/subs/{name}/status", getSubStatus).Methods("GET")
+
+	r.HandleFunc("/funcs/", getAllFunctions).Methods("GET")
+	r.HandleFunc("/{ns}/funcs/{name}", postFunction).Methods("POST")
+	r.HandleFunc("/{ns}/funcs/{name}", getFunction).Methods("GET")
+	r.HandleFunc("/{ns}/funcs/{name}", putFunction).Methods("PUT")
+	r.HandleFunc("/{ns}/funcs/{name}", delFunction).Methods("DELETE")
+	r.HandleFunc("/{ns}/funcs/{name}/logs", getFunctionLogs).Methods("GET")
+	r.HandleFunc("/{ns}/funcs/{name}/logs/stream", streamFunctionLogs).Methods("GET")
+	r.HandleFunc("/{ns}/funcs/{name}/diagnostics", getFunctionDiagnostics).Methods("GET")
+	r.HandleFunc("/{ns}/funcs/{name}/invoke", postFunctionInvoke).Methods("POST")
+
+	r.HandleFunc("/gitrepos", getAllGitRepositories).Methods("GET")
+	r.HandleFunc("/{ns}/gitrepos/{name}", postGitRepository).Methods("POST")
+	r.HandleFunc("/{ns}/gitrepos/{name}", getGitRepository).Methods("GET")
+	r.HandleFunc("/{ns}/gitrepos/{name}", putGitRepository).Methods("PUT")
+	r.HandleFunc("/{ns}/gitrepos/{name}", delGitRepository).Methods("DELETE")
+
+	r.HandleFunc("/publishEvent", publishEvent).Methods("POST")
+	r.HandleFunc("/events", postEvent).Methods("POST")
+
+	r.HandleFunc("/cleaneventtypes", getAllCleanEventTypes).Methods("GET")
+
+	r.HandleFunc("/watch", watchResources).Methods("GET")
+	r.HandleFunc("/cache", getCacheStatus).Methods("GET")
+}
+
 func commonMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -82,123 +103,46 @@ func commonMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func portForwardEPP() (*forwarder.Result, error) {
+// portForwardEPP opens a port-forward to the eventing publisher proxy on a local port chosen by the OS,
+// so that the port-forwards of the clusters do not collide. It waits for the port-forward until ctx is done.
+func portForwardEPP(ctx context.Context, config *rest.Config) (*forwarder.Result, error) {
 	options := []*forwarder.Option{
 		{
 			// https://github.com/anthhub/forwarder
 			// if local port isn't provided, forwarder will generate a random port number
 			// if target port isn't provided, forwarder find the first container port of the pod or service
-			LocalPort: 9091,
+			LocalPort: 0,
 			// the k8s pod port
-			RemotePort: 8080,
+			RemotePort: eppPodPort,
 			// the forwarding service name
-			ServiceName: "eventing-publisher-proxy",
+			ServiceName: eppServiceName,
 			// the k8s source string, eg: svc/my-nginx-svc po/my-nginx-666
 			// the Source field will be parsed and override ServiceName or RemotePort field
 			//Source: "svc/my-nginx-66b6c48dd5-ttdb2",
 			// namespace default is "default"
-			Namespace: "kyma-system",
+			Namespace: eppNamespace,
+			// a reconnect moves to another ready pod of the service
+			Selection: forwarder.SelectFailover,
 		},
 	}
 
-	ret, err := forwarder.Forwarders(context.Background(), options, k8sClientConfigs[defaultCluster])
+	// the port-forward is closed with its cluster, not with the request which opened it
+	ret, err := forwarder.Forwarders(context.Background(), options, config)
 	if err != nil {
 		return nil, err
 	}
 
-	//// remember to close the forwarding
-	//defer ret.Close()
-
 	// wait forwarding ready
-	// the remote and local ports are listed
-	ports, err := ret.Ready()
+	// the local port is read back from the forwarded ports
+	baseURL, err := forwardedURL(ctx, ret)
 	if err != nil {
+		ret.Close()
 		return nil, err
 	}
 
-	fmt.Printf("port-forward started to ports: %+v\n", ports)
-
-	return ret, err
-}
-
-func getKubeconfigs(w http.ResponseWriter, r *http.Request) {
-	keys := reflect.ValueOf(kubeconfigs).MapKeys()
-
-	data, err := json.Marshal(keys)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Return response to user
-	_, err = w.Write(data)
-	if err != nil {
-		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-}
-
-func addKubeconfig(w http.ResponseWriter, r *http.Request) {
-	// Fetch data from URI
-	name := mux.Vars(r)["name"]
-
-	data, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	kc := string(data)
-	if kc == "" {
-		log.Printf("%s %s Invalid req body", r.Method, r.RequestURI)
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-
-	kubeconfigs[name] = kc
-
-	k8sConfig, err := clientcmd.NewClientConfigFromBytes([]byte(kc))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	clientConfig, err := k8sConfig.ClientConfig()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
+	log.Printf("port-forward to EPP started on %s", baseURL)
 
-	k8sClientConfigs[name] = clientConfig
-	// Create dynamic client (k8s)
-	dynamicClient, err := dynamic.NewForConfig(clientConfig)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// setup clients
-	resourceClients := &K8sResourceClients{
-		subscriptionClient: subscription.NewClient(dynamicClient),
-		functionClient:     function.NewClient(dynamicClient),
-	}
-
-	K8sClients[name] = resourceClients
-
-	kubeconfigs[defaultCluster] = kubeconfigs[name]
-	k8sClientConfigs[defaultCluster] = k8sClientConfigs[name]
-	K8sClients[defaultCluster] = K8sClients[name]
-
-	// start the port-forward to EPP
-	portForwardResult, err = portForwardEPP()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	log.Print("kubeconfig was set")
+	return ret, nil
 }
 
 func getAllSubs(w http.ResponseWriter, r *http.Request) {
@@ -211,8 +155,12 @@ func getAllSubs(w http.ResponseWriter, r *http.Request) {
 		namespace = v.Get("ns")
 	}
 
-	// Get subscriptions from the k8s cluster
-	subsUnstructured, err := K8sClients[defaultCluster].subscriptionClient.ListJson(namespace)
+	// Get subscriptions from the informer cache or the k8s cluster
+	client := clusterFromContext(r.Context()).SubscriptionClient
+	if freshParam(r) {
+		client = client.Fresh()
+	}
+	subsUnstructured, err := client.ListJson(namespace)
 	if err != nil {
 		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -246,8 +194,12 @@ func getAllCleanEventTypes(w http.ResponseWriter, r *http.Request) {
 		namespace = v.Get("ns")
 	}
 
-	// Get subscriptions from the k8s cluster
-	subList, err := K8sClients[defaultCluster].subscriptionClient.List(namespace)
+	// Get subscriptions from the informer cache or the k8s cluster
+	client := clusterFromContext(r.Context()).SubscriptionClient
+	if freshParam(r) {
+		client = client.Fresh()
+	}
+	subList, err := client.List(namespace)
 	if err != nil {
 		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -255,13 +207,11 @@ func getAllCleanEventTypes(w http.ResponseWriter, r *http.Request) {
 	}
 
 	cleanEventTypes := make([]string, 0)
+	seen := make(map[string]bool)
 	for _, sub := range subList.Items {
-		if sub.Status.CleanEventTypes == nil {
-			continue
-		}
-
 		for _, cleanedType := range sub.Status.CleanEventTypes {
-			if !contains(cleanEventTypes, cleanedType) {
+			if !seen[cleanedType] {
+				seen[cleanedType] = true
 				cleanEventTypes = append(cleanEventTypes, cleanedType)
 			}
 		}
@@ -288,54 +238,45 @@ func postSub(w http.ResponseWriter, r *http.Request) {
 	namespace := mux.Vars(r)["ns"]
 	name := mux.Vars(r)["name"]
 
+	wait, err := waitParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Fetch data from request body
 	var newSubData SubscriptionData
-	err := json.NewDecoder(r.Body).Decode(&newSubData)
+	err = json.NewDecoder(r.Body).Decode(&newSubData)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var eventTypes []string
-	newType := fmt.Sprintf("sap.kyma.custom.%s.%s.%s", newSubData.AppName, newSubData.EventName, newSubData.EventVersion)
-	eventTypes = append(eventTypes, newType)
-
-	// Initialize a subscription object
-	newSub := &eventingv1alpha1.Subscription{
-		Spec: eventingv1alpha1.SubscriptionSpec{
-			Sink:   newSubData.Sink,
-			Filter: &eventingv1alpha1.BEBFilters{},
-		},
+	cluster := clusterFromContext(r.Context())
+	prefix, err := subscriptionEventTypePrefix(r.Context(), cluster, newSubData)
+	if err != nil {
+		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-	newSub.Kind = "Subscription"
-	newSub.APIVersion = "eventing.kyma-project.io/v1alpha1"
-	newSub.Name = name
-	newSub.Namespace = namespace
-
-	for _, eventType := range eventTypes {
-		eventFilter := &eventingv1alpha1.BEBFilter{
-			EventSource: &eventingv1alpha1.Filter{
-				Property: "source",
-				Type:     "exact",
-				Value:    "",
-			},
-			EventType: &eventingv1alpha1.Filter{
-				Property: "type",
-				Type:     "exact",
-				Value:    eventType,
-			},
-		}
 
-		newSub.Spec.Filter.Filters = append(newSub.Spec.Filter.Filters, eventFilter)
+	newSub, err := buildSubscription(name, namespace, newSubData, prefix)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	// Create subscription on the k8s cluster
-	_, err = K8sClients[defaultCluster].subscriptionClient.CreateSubscription(*newSub)
+	_, err = cluster.SubscriptionClient.CreateSubscription(*newSub)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if wait > 0 {
+		respondSubscriptionReady(w, r, cluster, name, namespace, wait, http.StatusCreated)
+		return
+	}
 	w.WriteHeader(http.StatusCreated)
 }
 
@@ -344,7 +285,7 @@ func getSub(w http.ResponseWriter, r *http.Request) {
 	namespace := mux.Vars(r)["ns"]
 	name := mux.Vars(r)["name"]
 
-	subUnstructured, err := K8sClients[defaultCluster].subscriptionClient.GetSubJson(name, namespace)
+	subUnstructured, err := clusterFromContext(r.Context()).SubscriptionClient.GetSubJson(name, namespace)
 	if err != nil {
 		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -374,54 +315,45 @@ func putSub(w http.ResponseWriter, r *http.Request) {
 		namespace = "default"
 	}
 
+	wait, err := waitParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Fetch data from request body
 	var newSubData SubscriptionData
-	err := json.NewDecoder(r.Body).Decode(&newSubData)
+	err = json.NewDecoder(r.Body).Decode(&newSubData)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var eventTypes []string
-	newType := fmt.Sprintf("sap.kyma.custom.%s.%s.%s", newSubData.AppName, newSubData.EventName, newSubData.EventVersion)
-	eventTypes = append(eventTypes, newType)
-
-	// Initialize a subscription object
-	newSub := &eventingv1alpha1.Subscription{
-		Spec: eventingv1alpha1.SubscriptionSpec{
-			Sink:   newSubData.Sink,
-			Filter: &eventingv1alpha1.BEBFilters{},
-		},
+	cluster := clusterFromContext(r.Context())
+	prefix, err := subscriptionEventTypePrefix(r.Context(), cluster, newSubData)
+	if err != nil {
+		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-	newSub.Kind = "Subscription"
-	newSub.APIVersion = "eventing.kyma-project.io/v1alpha1"
-	newSub.Name = name
-	newSub.Namespace = namespace
-
-	for _, eventType := range eventTypes {
-		eventFilter := &eventingv1alpha1.BEBFilter{
-			EventSource: &eventingv1alpha1.Filter{
-				Property: "source",
-				Type:     "exact",
-				Value:    "",
-			},
-			EventType: &eventingv1alpha1.Filter{
-				Property: "type",
-				Type:     "exact",
-				Value:    eventType,
-			},
-		}
 
-		newSub.Spec.Filter.Filters = append(newSub.Spec.Filter.Filters, eventFilter)
+	newSub, err := buildSubscription(name, namespace, newSubData, prefix)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	// Create subscription on the k8s cluster
-	_, err = K8sClients[defaultCluster].subscriptionClient.UpdateSubscription(*newSub)
+	// Update subscription on the k8s cluster
+	_, err = cluster.SubscriptionClient.UpdateSubscription(*newSub)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if wait > 0 {
+		respondSubscriptionReady(w, r, cluster, name, namespace, wait, http.StatusOK)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -432,7 +364,7 @@ func delSub(w http.ResponseWriter, r *http.Request) {
 
 	// check
 	// Delete subscription
-	err := K8sClients[defaultCluster].subscriptionClient.DeleteSubscription(name, namespace)
+	err := clusterFromContext(r.Context()).SubscriptionClient.DeleteSubscription(name, namespace)
 	if err != nil {
 		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -442,39 +374,6 @@ func delSub(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func postFunction(w http.ResponseWriter, r *http.Request) {
-	name := mux.Vars(r)["name"]
-	namespace := mux.Vars(r)["ns"]
-	if namespace == "" {
-		namespace = "default"
-	}
-
-	// initialize a function object
-	var minReplicas int32 = 1
-	var maxReplicas int32 = 5
-	newFunction := serverlessv1alpha1.Function{
-		Spec: serverlessv1alpha1.FunctionSpec{
-			MinReplicas: &minReplicas,
-			MaxReplicas: &maxReplicas,
-		},
-	}
-	newFunction.Spec.Deps = "{ \n  \"name\": \"test\",\n  \"version\": \"1.0.0\",\n  \"dependencies\":{}\n}"
-	newFunction.Spec.Source = "module.exports = {\n main: function (event, context) {\n  console.log(event.data);\n  return \"Hello World!\";\n  }\n}"
-	newFunction.Spec.Runtime = serverlessv1alpha1.Nodejs16
-	newFunction.APIVersion = "serverless.kyma-project.io/v1alpha1"
-	newFunction.Kind = "Function"
-	newFunction.Name = name
-	newFunction.Namespace = namespace
-
-	_, err := K8sClients[defaultCluster].functionClient.CreateFunction(newFunction)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-}
-
 func getAllFunctions(w http.ResponseWriter, r *http.Request) {
 	logHitEndpoint(r.RequestURI)
 	namespace := "default"
@@ -486,9 +385,13 @@ func getAllFunctions(w http.ResponseWriter, r *http.Request) {
 		namespace = v.Get("ns")
 	}
 
-	// Get tiny functions from the k8s cluster
+	// Get tiny functions from the informer cache or the k8s cluster
 	// tiny functions only hold name, namespace and source
-	fnBytes, err := K8sClients[defaultCluster].functionClient.MarshaledTinyFunctionList(namespace)
+	client := clusterFromContext(r.Context()).FunctionClient
+	if freshParam(r) {
+		client = client.Fresh()
+	}
+	fnBytes, err := client.MarshaledTinyFunctionList(namespace)
 	if err != nil {
 		log.Printf("%s %s failed to marchal json: %v", r.Method, r.RequestURI, err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -513,7 +416,7 @@ func getFunction(w http.ResponseWriter, r *http.Request) {
 		namespace = "default"
 	}
 
-	fnUnstructured, err := K8sClients[defaultCluster].functionClient.GetFnJson(name, namespace)
+	fnUnstructured, err := clusterFromContext(r.Context()).FunctionClient.GetFnJson(name, namespace)
 	if err != nil {
 		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -535,12 +438,6 @@ func getFunction(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func putFunction(w http.ResponseWriter, r *http.Request) {
-	logHitEndpoint(r.RequestURI)
-	//TODO?
-	w.WriteHeader(http.StatusOK)
-}
-
 func delFunction(w http.ResponseWriter, r *http.Request) {
 	logHitEndpoint(r.RequestURI)
 	// Fetch data from URI
@@ -551,7 +448,7 @@ func delFunction(w http.ResponseWriter, r *http.Request) {
 	}
 	// check
 	// Delete subscription
-	err := K8sClients[defaultCluster].functionClient.DeleteFunction(name, namespace)
+	err := clusterFromContext(r.Context()).FunctionClient.DeleteFunction(name, namespace)
 	if err != nil {
 		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -561,90 +458,6 @@ func delFunction(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func getFunctionLogs(w http.ResponseWriter, r *http.Request) {
-	logHitEndpoint(r.RequestURI)
-	// Fetch data from URI
-	namespace := mux.Vars(r)["ns"]
-	name := mux.Vars(r)["name"]
-
-	// check
-	// Delete subscription
-	logsData, err := K8sClients[defaultCluster].functionClient.GetFunctionLogs(name, namespace, k8sClientConfigs[defaultCluster])
-	if err != nil {
-		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	var prettyData string
-	for _, d := range logsData {
-		prettyData = d
-	}
-
-	// Convert response to bytes
-	data, err := json.Marshal(prettyData)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Return response to user
-	// w.Header().Set("Content-Type", "text/plain")
-	_, err = w.Write([]byte(data))
-	if err != nil {
-		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
-	}
-}
-
-func publishEvent(w http.ResponseWriter, r *http.Request) {
-	// forward the event to EPP
-	response, err := forwardEventToEPP(r)
-	if err != nil {
-		portForwardResult, err = portForwardEPP()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		// try again
-		response, err = forwardEventToEPP(r)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	}
-
-	w.WriteHeader(response.StatusCode)
-}
-
-func forwardEventToEPP(r *http.Request) (*http.Response, error) {
-	// forward the event to EPP
-	newRequest, err := http.NewRequest("POST", "http://localhost:9091/publish", r.Body)
-	if err != nil {
-		return nil, err
-	}
-	newRequest.Header = r.Header.Clone()
-
-	client := &http.Client{}
-	response, err := client.Do(newRequest)
-	if err != nil {
-		return nil, err
-	}
-	defer response.Body.Close()
-
-	return response, nil
-}
-
-func contains(s []string, str string) bool {
-	for _, v := range s {
-		if v == str {
-			return true
-		}
-	}
-
-	return false
-}
-
 func logHitEndpoint(endpoint string) {
 	log.Printf("hit endpoint %s", endpoint)
 }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/vladislavpaskar/hackathon2022/components/backend/store"
+)
+
+const (
+	// clusterStoreEnv selects the store of the registered clusters: file or secret.
+	// The clusters are kept in memory only if it is not set.
+	clusterStoreEnv = "CLUSTER_STORE"
+	// clusterStoreDirEnv is the directory of the file store
+	clusterStoreDirEnv = "CLUSTER_STORE_DIR"
+	// clusterStoreNamespaceEnv is the namespace of the secret store, it defaults to the namespace of the pod
+	clusterStoreNamespaceEnv = "CLUSTER_STORE_NAMESPACE"
+	// clusterStoreKeyEnv is the base64 encoded 32 bytes key to encrypt the stored kubeconfigs
+	clusterStoreKeyEnv = "CLUSTER_STORE_KEY"
+
+	defaultClusterStoreDir = "/var/lib/backend/clusters"
+	namespaceFile          = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// clusterStore persists the registered clusters, it is nil if the clusters are kept in memory only
+var clusterStore store.Store
+
+// newClusterStore creates the cluster store configured by the environment
+func newClusterStore() (store.Store, error) {
+	kind := os.Getenv(clusterStoreEnv)
+	if kind == "" {
+		return nil, nil
+	}
+
+	cipher, err := store.NewCipher(os.Getenv(clusterStoreKeyEnv))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", clusterStoreKeyEnv, err)
+	}
+
+	switch kind {
+	case "file":
+		dir := os.Getenv(clusterStoreDirEnv)
+		if dir == "" {
+			dir = defaultClusterStoreDir
+		}
+		return store.NewFileStore(dir, cipher)
+	case "secret":
+		clientset, err := inClusterClientset()
+		if err != nil {
+			return nil, err
+		}
+		return store.NewSecretStore(clientset, podNamespace(), cipher), nil
+	default:
+		return nil, fmt.Errorf("unknown %s: %s", clusterStoreEnv, kind)
+	}
+}
+
+// podNamespace returns the namespace for the secret store
+func podNamespace() string {
+	if namespace := os.Getenv(clusterStoreNamespaceEnv); namespace != "" {
+		return namespace
+	}
+	return ownNamespace()
+}
+
+// ownNamespace returns the namespace the backend runs in, it is "default" outside of a cluster
+func ownNamespace() string {
+	if data, err := os.ReadFile(namespaceFile); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	return "default"
+}
+
+// restoreClusters registers the stored clusters. The port-forwards to EPP are opened in the background,
+// so that unreachable clusters do not delay the start. A port-forward which could not be opened is retried on the first publish.
+func restoreClusters() error {
+	if clusterStore == nil {
+		return nil
+	}
+
+	records, err := clusterStore.List()
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		cluster, err := NewCluster(record.Owner, record.Name, record.Kubeconfig, record.Context)
+		if err != nil {
+			log.Printf("failed to restore cluster %s: %v", record.Name, err)
+			continue
+		}
+		cluster.SetEventTypePrefixOverride(record.EventTypePrefix)
+
+		cluster.SetEPPTransportOverride(EPPTransportKind(record.EPPTransport))
+
+		clusters.Replace(cluster)
+		log.Printf("restored cluster %s", record.Name)
+
+		go func(cluster *Cluster) {
+			if err := cluster.EPPTransport(context.Background()).Connect(context.Background()); err != nil {
+				log.Printf("failed to connect to EPP of restored cluster %s: %v", cluster.Name, err)
+			}
+		}(cluster)
+	}
+	return nil
+}
+
+// persistCluster saves the cluster to the cluster store
+func persistCluster(cluster *Cluster) error {
+	return persistRecord(clusterRecord(cluster))
+}
+
+// persistRecord saves the record to the cluster store
+func persistRecord(record store.Record) error {
+	if clusterStore == nil {
+		return nil
+	}
+	return clusterStore.Save(record)
+}
+
+// clusterRecord returns the record which stores the cluster
+func clusterRecord(cluster *Cluster) store.Record {
+	return store.Record{
+		Owner:      cluster.Owner,
+		Name:       cluster.Name,
+		Kubeconfig: cluster.Kubeconfig,
+		Context:    cluster.Context,
+		// the discovered prefix is not stored, it may change in the cluster
+		EventTypePrefix: cluster.EventTypePrefixOverride(),
+		EPPTransport:    string(cluster.EPPTransportOverride()),
+	}
+}
+
+// forgetCluster deletes the cluster of the owner from the cluster store
+func forgetCluster(owner, name string) error {
+	if clusterStore == nil {
+		return nil
+	}
+	return clusterStore.Delete(owner, name)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/publisher"
+)
+
+const (
+	// eppTimeout is the default timeout of a request to the eventing publisher proxy
+	eppTimeout = 10 * time.Second
+	// maxEPPTimeout is the longest timeout a publish request can ask for
+	maxEPPTimeout = time.Minute
+	// maxEPPResponseSize is the size of the response body of the publisher proxy returned to the client
+	maxEPPResponseSize = 1 << 20
+	// maxEventSize is the size of the largest event passed through to the publisher proxy
+	maxEventSize = 4 << 20
+)
+
+// codes of the PublishError
+const (
+	PublishErrForwarderUnavailable = "forwarder_unavailable"
+	PublishErrUnreachable          = "epp_unreachable"
+	PublishErrTimeout              = "epp_timeout"
+)
+
+// eppResponseHeaders are the response headers of the publisher proxy which are passed through to the client
+var eppResponseHeaders = []string{"Content-Type", "Retry-After", "X-Request-Id"}
+
+// PublishError is the error envelope of a publish request which did not get a response from the publisher proxy
+type PublishError struct {
+	Code    string `json:"code"` // Code is forwarder_unavailable, epp_unreachable or epp_timeout
+	Message string `json:"error"`
+	status  int
+}
+
+func (e *PublishError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// newPublishError classifies the error of a request to the publisher proxy:
+// 504 on timeouts, 503 if the port-forward can not be opened, otherwise 502
+func newPublishError(err error) *PublishError {
+	var publishErr *PublishError
+	switch {
+	case errors.As(err, &publishErr):
+		return publishErr
+	case errors.Is(err, context.DeadlineExceeded):
+		return &PublishError{Code: PublishErrTimeout, Message: err.Error(), status: http.StatusGatewayTimeout}
+	default:
+		return &PublishError{Code: PublishErrUnreachable, Message: err.Error(), status: http.StatusBadGateway}
+	}
+}
+
+// writePublishError responds with the PublishError envelope
+func writePublishError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+	publishErr := newPublishError(err)
+
+	data, err := json.Marshal(publishErr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(publishErr.status)
+	_, err = w.Write(data)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
+
+// eppTimeoutParam returns the timeout of the timeout query parameter, e.g. 5s
+func eppTimeoutParam(r *http.Request) (time.Duration, error) {
+	param := r.URL.Query().Get("timeout")
+	if param == "" {
+		return eppTimeout, nil
+	}
+	timeout, err := time.ParseDuration(param)
+	if err != nil || timeout <= 0 || timeout > maxEPPTimeout {
+		return 0, fmt.Errorf("invalid timeout %q, expected a duration up to %s, e.g. 5s", param, maxEPPTimeout)
+	}
+	return timeout, nil
+}
+
+// PublishData is the request body to publish an event, the event fields are inlined
+type PublishData struct {
+	publisher.Event
+	Mode publisher.Mode `json:"mode,omitempty"` // Mode is binary, structured or legacy, default: binary
+	App  string         `json:"app,omitempty"`  // App is the application of a legacy event
+}
+
+// PublishResult is the response of the publisher proxy to a published event
+type PublishResult struct {
+	ID     string         `json:"id"`
+	Mode   publisher.Mode `json:"mode"`
+	Status int            `json:"status"` // Status is the status code of the publisher proxy
+	// Response is the body of the publisher proxy, e.g. the validation error, a body which is not JSON is a string
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// readEPPResponse returns the body of the response as JSON, a body which is not JSON is returned as JSON string
+func readEPPResponse(response *http.Response) (json.RawMessage, error) {
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxEPPResponseSize))
+	if err != nil || len(body) == 0 {
+		return nil, err
+	}
+	if json.Valid(body) {
+		return body, nil
+	}
+	return json.Marshal(string(body))
+}
+
+// postEvent publishes a typed CloudEvent, the missing id and time are filled in
+func postEvent(w http.ResponseWriter, r *http.Request) {
+	// Fetch data from request body
+	var data PublishData
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if data.Mode == "" {
+		data.Mode = publisher.ModeBinary
+	}
+	// the event is validated before the publisher proxy is reached
+	data.Event.Default()
+	if err := data.Event.Validate(data.Mode, data.App); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	timeout, err := eppTimeoutParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	response, err := clusterFromContext(r.Context()).EPPTransport(ctx).Send(ctx, func(ctx context.Context, baseURL string) (*http.Request, error) {
+		return publisher.NewRequest(ctx, baseURL, &data.Event, data.Mode, data.App)
+	})
+	if err != nil {
+		writePublishError(w, r, err)
+		return
+	}
+	defer response.Body.Close()
+
+	result := PublishResult{ID: data.ID, Mode: data.Mode, Status: response.StatusCode}
+	result.Response, err = readEPPResponse(response)
+	if err != nil {
+		log.Printf("%s %s failed to read the response of the publisher proxy: %v", r.Method, r.RequestURI, err)
+	}
+
+	// Convert response to bytes
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response to user, a rejected event is returned with the status of the publisher proxy
+	if response.StatusCode >= http.StatusBadRequest {
+		w.WriteHeader(response.StatusCode)
+	}
+	_, err = w.Write(resultBytes)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
+
+// publishEvent passes the raw event through to the /publish endpoint of the publisher proxy
+// and responds with the status, the body and the relevant headers of the publisher proxy
+func publishEvent(w http.ResponseWriter, r *http.Request) {
+	timeout, err := eppTimeoutParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Fetch data from request body, it is buffered to send it again after reopening the port-forward
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEventSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxEventSize {
+		http.Error(w, fmt.Sprintf("the event exceeds %d bytes", maxEventSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	response, err := clusterFromContext(r.Context()).EPPTransport(ctx).Send(ctx, func(ctx context.Context, baseURL string) (*http.Request, error) {
+		return newEPPPassthroughRequest(ctx, baseURL, r, body)
+	})
+	if err != nil {
+		writePublishError(w, r, err)
+		return
+	}
+	defer response.Body.Close()
+
+	// Return response to user
+	for _, header := range eppResponseHeaders {
+		if value := response.Header.Get(header); value != "" {
+			w.Header().Set(header, value)
+		} else {
+			w.Header().Del(header)
+		}
+	}
+	w.WriteHeader(response.StatusCode)
+	_, err = io.Copy(w, io.LimitReader(response.Body, maxEPPResponseSize))
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
+
+// newEPPPassthroughRequest returns the request which passes the body and the headers of the request through to EPP
+func newEPPPassthroughRequest(ctx context.Context, baseURL string, r *http.Request, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/publish", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+	// the credentials of the backend must not be passed on to EPP
+	req.Header.Del("Authorization")
+	req.Header.Del(clusterHeader)
+	// the body of the response is passed through as is, it must not be compressed for the client of the backend
+	req.Header.Del("Accept-Encoding")
+	req.Header.Del("Connection")
+	return req, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// newFakeEPP starts a publisher proxy which rejects events without data,
+// it is reached by DNS from the returned cluster which the backend runs in
+func newFakeEPP() (*http.Request, *Cluster, func()) {
+	received := new(http.Request)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*received = *r.Clone(context.Background())
+		if r.ContentLength == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"type":"validation_error","message":"data is missing"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	original := eppServiceURL
+	eppServiceURL = server.URL
+	cluster := &Cluster{inCluster: true, locationKnown: true}
+	cluster.SetEPPTransportOverride(EPPTransportDNS)
+	return received, cluster, func() {
+		eppServiceURL = original
+		server.Close()
+	}
+}
+
+func TestPostEvent(t *testing.T) {
+	received, cluster, stop := newFakeEPP()
+	defer stop()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/events", strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), clusterContextKey{}, cluster))
+		rec := httptest.NewRecorder()
+		postEvent(rec, req)
+		return rec
+	}
+
+	rec := post(`{"source": "shop", "type": "sap.kyma.custom.shop.order.created.v1", "data": {"orderId": 42}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result PublishResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.ID == "" || result.Mode != "binary" || result.Status != http.StatusNoContent {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if received.URL.Path != "/publish" || received.Header.Get("ce-id") != result.ID {
+		t.Fatalf("expected a binary CloudEvent with the generated id, got: %s %v", received.URL.Path, received.Header)
+	}
+
+	rec = post(`{"source": "shop", "type": "sap.kyma.custom.shop.order.created.v1"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected the status of the publisher proxy, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(result.Response), "data is missing") {
+		t.Fatalf("expected the error of the publisher proxy, got: %s", result.Response)
+	}
+
+	if rec := post(`{"type": "order.created.v1", "mode": "legacy"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a legacy event without app, got %d", rec.Code)
+	}
+}
+
+func TestPublishEvent(t *testing.T) {
+	received, cluster, stop := newFakeEPP()
+	defer stop()
+
+	publish := func(target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/cloudevents+json")
+		req.Header.Set("Authorization", "Bearer secret")
+		req = req.WithContext(context.WithValue(req.Context(), clusterContextKey{}, cluster))
+		rec := httptest.NewRecorder()
+		// the content type set by the common middleware
+		rec.Header().Set("Content-Type", "application/json")
+		publishEvent(rec, req)
+		return rec
+	}
+
+	rec := publish("/api/publishEvent", `{"specversion": "1.0"}`)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Content-Type") != "" {
+		t.Fatalf("expected the status and headers of the publisher proxy, got %d: %v", rec.Code, rec.Header())
+	}
+	if received.Header.Get("Authorization") != "" || received.Header.Get("Content-Type") != "application/cloudevents+json" {
+		t.Fatalf("expected the headers without credentials to be passed through, got: %v", received.Header)
+	}
+
+	rec = publish("/api/publishEvent", ``)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "data is missing") {
+		t.Fatalf("expected the validation error of the publisher proxy, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := publish("/api/publishEvent?timeout=2h", `{}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a too long timeout, got %d", rec.Code)
+	}
+}
+
+func TestPublishEventErrors(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the body is read to notice the client closing the connection
+		_, _ = io.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	original := eppServiceURL
+	defer func() { eppServiceURL = original }()
+
+	publish := func(target string, cluster *Cluster) (*httptest.ResponseRecorder, PublishError) {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{}`))
+		req = req.WithContext(context.WithValue(req.Context(), clusterContextKey{}, cluster))
+		rec := httptest.NewRecorder()
+		publishEvent(rec, req)
+
+		var envelope PublishError
+		if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("expected an error envelope, got %d: %s", rec.Code, rec.Body.String())
+		}
+		return rec, envelope
+	}
+
+	eppServiceURL = slow.URL
+	dnsCluster := &Cluster{inCluster: true, locationKnown: true}
+	dnsCluster.SetEPPTransportOverride(EPPTransportDNS)
+	rec, envelope := publish("/api/publishEvent?timeout=50ms", dnsCluster)
+	if rec.Code != http.StatusGatewayTimeout || envelope.Code != PublishErrTimeout {
+		t.Fatalf("expected 504 for a timeout, got %d: %+v", rec.Code, envelope)
+	}
+
+	// the API server to open the port-forward to the publisher proxy is not reachable
+	portForwardCluster := &Cluster{RestConfig: &rest.Config{Host: "http://127.0.0.1:1"}}
+	portForwardCluster.SetEPPTransportOverride(EPPTransportPortForward)
+	rec, envelope = publish("/api/publishEvent", portForwardCluster)
+	if rec.Code != http.StatusServiceUnavailable || envelope.Code != PublishErrForwarderUnavailable || envelope.Message == "" {
+		t.Fatalf("expected 503 if the port-forward can not be opened, got %d: %+v", rec.Code, envelope)
+	}
+}
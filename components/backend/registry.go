@@ -0,0 +1,459 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/forwarder"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/function"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/gitrepository"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/informer"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/subscription"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var (
+	// ErrClusterExists is returned when adding a cluster with an already registered name
+	ErrClusterExists = errors.New("cluster already exists")
+	// ErrClusterNotFound is returned when the requested cluster is not registered
+	ErrClusterNotFound = errors.New("cluster not found")
+)
+
+// locationRetryInterval is how long a cluster is treated as foreign after its location could not be looked up
+const locationRetryInterval = time.Minute
+
+// Cluster holds the kubeconfig and the clients of a registered cluster
+type Cluster struct {
+	Owner               string // Owner is the user who registered the cluster, it is empty without authentication
+	Name                string
+	Kubeconfig          string
+	Context             string // Context is the kubeconfig context used for the clients
+	RestConfig          *rest.Config
+	DynamicClient       dynamic.Interface
+	Clientset           kubernetes.Interface
+	SubscriptionClient  subscription.Client // SubscriptionClient lists from Cache, see subscription.Client.Fresh
+	FunctionClient      function.Client     // FunctionClient lists from Cache, see function.Client.Fresh
+	GitRepositoryClient gitrepository.Client
+	Cache               *informer.Cache
+
+	// mu guards the EPP forwarder which is replaced when the tunnel breaks and the EPP transport override
+	mu           sync.Mutex
+	forwarder    *forwarder.Result
+	eppTransport EPPTransportKind // eppTransport overrides the transport configured by the environment
+	closed       bool             // closed is set once the cluster was removed or renamed, it takes no forwarder anymore
+	// connectMu serializes opening the EPP forwarder, so that concurrent requests open only one
+	connectMu sync.Mutex
+
+	// prefixMu guards the event type prefixes
+	prefixMu         sync.Mutex
+	eventTypePrefix  string // eventTypePrefix overrides the prefix configured in the cluster
+	discoveredPrefix string // discoveredPrefix caches the prefix configured in the cluster
+	prefixDiscovered bool
+
+	// locationMu guards whether the backend runs inside the cluster
+	locationMu    sync.Mutex
+	inCluster     bool
+	locationKnown bool
+	locationRetry time.Time // locationRetry is when a failed lookup of the location is retried
+
+	informerWatches int32 // informerWatches is the number of watch streams with their own informers, see maxInformerWatches
+}
+
+// NewCluster parses the kubeconfig and creates the clients for the given context,
+// the current context of the kubeconfig is used if the context is empty.
+// A failure is reported as ValidationError.
+func NewCluster(owner, name, kubeconfig, context string) (*Cluster, error) {
+	config, err := clientcmd.Load([]byte(kubeconfig))
+	if err != nil {
+		return nil, newValidationError(StepParse, err)
+	}
+
+	if context == "" {
+		context = config.CurrentContext
+	}
+	if _, ok := config.Contexts[context]; !ok {
+		return nil, newValidationError(StepParse, fmt.Errorf("context %q not found in kubeconfig", context))
+	}
+	if err := checkCredentials(config, context); err != nil {
+		return nil, newValidationError(StepParse, err)
+	}
+
+	k8sConfig := clientcmd.NewNonInteractiveClientConfig(*config, context, &clientcmd.ConfigOverrides{}, nil)
+	restConfig, err := k8sConfig.ClientConfig()
+	if err != nil {
+		return nil, newValidationError(StepRestConfig, err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, newValidationError(StepClients, err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, newValidationError(StepClients, err)
+	}
+
+	// the cache is started by the first list
+	cache := informer.New(dynamicClient, subscription.GroupVersionResource(), function.GroupVersionResource())
+
+	return &Cluster{
+		Owner:               owner,
+		Name:                name,
+		Kubeconfig:          kubeconfig,
+		Context:             context,
+		RestConfig:          restConfig,
+		DynamicClient:       dynamicClient,
+		Clientset:           clientset,
+		SubscriptionClient:  subscription.NewClient(dynamicClient).WithLister(cache.Lister(subscription.GroupVersionResource())),
+		FunctionClient:      function.NewClient(dynamicClient).WithLister(cache.Lister(function.GroupVersionResource())),
+		GitRepositoryClient: gitrepository.NewClient(dynamicClient),
+		Cache:               cache,
+	}, nil
+}
+
+// EventTypePrefix returns the event type prefix of the cluster: the override if it is set,
+// otherwise the prefix configured in the cluster or defaultEventTypePrefix.
+// defaultEventTypePrefix is also used if the kubeconfig must not read the configuration of the cluster.
+func (c *Cluster) EventTypePrefix(ctx context.Context) (string, error) {
+	c.prefixMu.Lock()
+	defer c.prefixMu.Unlock()
+
+	if c.eventTypePrefix != "" {
+		return c.eventTypePrefix, nil
+	}
+	if !c.prefixDiscovered {
+		prefix, err := discoverEventTypePrefix(ctx, c.Clientset)
+		if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
+			// the fallback is cached, so that it is only logged once
+			log.Printf("failed to read the event type prefix of cluster %s, using %s: %v", c.Name, defaultEventTypePrefix, err)
+			prefix, err = "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		c.discoveredPrefix = prefix
+		c.prefixDiscovered = true
+	}
+	if c.discoveredPrefix != "" {
+		return c.discoveredPrefix, nil
+	}
+	return defaultEventTypePrefix, nil
+}
+
+// EventTypePrefixOverride returns the event type prefix which overrides the one configured in the cluster
+func (c *Cluster) EventTypePrefixOverride() string {
+	c.prefixMu.Lock()
+	defer c.prefixMu.Unlock()
+	return c.eventTypePrefix
+}
+
+// SetEventTypePrefixOverride overrides the event type prefix configured in the cluster, an empty prefix removes the override
+func (c *Cluster) SetEventTypePrefixOverride(prefix string) {
+	c.prefixMu.Lock()
+	defer c.prefixMu.Unlock()
+	c.eventTypePrefix = prefix
+}
+
+// InCluster reports whether the backend runs inside the cluster, so that its services are reachable by DNS.
+// The cluster is identified by the UID of its kube-system namespace. If the lookup fails, e.g. because it is forbidden,
+// the backend runs outside of the cluster until the lookup is retried after locationRetryInterval.
+func (c *Cluster) InCluster(ctx context.Context) bool {
+	c.locationMu.Lock()
+	inCluster, locationKnown, locationRetry := c.inCluster, c.locationKnown, c.locationRetry
+	c.locationMu.Unlock()
+	if locationKnown {
+		return inCluster
+	}
+	if time.Now().Before(locationRetry) {
+		return false
+	}
+
+	// the API server is asked without holding the lock, so that e.g. a rename of the cluster does not wait for it
+	if own := ownClusterUID(); own != "" {
+		uid, err := clusterUID(ctx, c.Clientset)
+		if err != nil {
+			log.Printf("failed to identify cluster %s, retrying in %s: %v", c.Name, locationRetryInterval, err)
+			c.locationMu.Lock()
+			defer c.locationMu.Unlock()
+			c.locationRetry = time.Now().Add(locationRetryInterval)
+			return false
+		}
+		inCluster = uid == own
+	}
+
+	c.locationMu.Lock()
+	defer c.locationMu.Unlock()
+	c.inCluster, c.locationKnown = inCluster, true
+	return inCluster
+}
+
+// EPPTransport returns the transport to the eventing publisher proxy of the cluster:
+// the override if it is set, otherwise the transport configured by the environment.
+// A dns override is ignored unless the backend runs in the cluster, see checkEPPTransportOverride.
+func (c *Cluster) EPPTransport(ctx context.Context) eppTransport {
+	kind := c.EPPTransportOverride()
+	if checkEPPTransportOverride(ctx, c, kind) != nil {
+		kind = ""
+	}
+	if kind == "" {
+		kind = defaultEPPTransportKind()
+	}
+	return newEPPTransport(ctx, c, kind)
+}
+
+// EPPTransportOverride returns the transport which overrides the one configured by the environment
+func (c *Cluster) EPPTransportOverride() EPPTransportKind {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.eppTransport
+}
+
+// SetEPPTransportOverride overrides the transport configured by the environment, an empty transport removes the override.
+// The port-forward is closed if the cluster does not use it anymore.
+func (c *Cluster) SetEPPTransportOverride(kind EPPTransportKind) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.eppTransport = kind
+	if kind != "" && kind != EPPTransportAuto && kind != EPPTransportPortForward && c.forwarder != nil {
+		c.forwarder.Close()
+		c.forwarder = nil
+	}
+}
+
+// Forwarder returns the EPP port-forward of the cluster or nil if there is none
+func (c *Cluster) Forwarder() *forwarder.Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.forwarder
+}
+
+// SetForwarder sets the EPP port-forward of the cluster and closes the previous one.
+// The port-forward is closed right away if the cluster was already closed, so that it does not leak.
+func (c *Cluster) SetForwarder(result *forwarder.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.forwarder != nil && c.forwarder != result {
+		c.forwarder.Close()
+	}
+	if c.closed && result != nil {
+		result.Close()
+		result = nil
+	}
+	c.forwarder = result
+}
+
+// withName returns a copy of the cluster with the new name which takes over the EPP forwarder and the cache
+func (c *Cluster) withName(name string) *Cluster {
+	// the location is copied before the other locks are taken
+	c.locationMu.Lock()
+	inCluster, locationKnown, locationRetry := c.inCluster, c.locationKnown, c.locationRetry
+	c.locationMu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prefixMu.Lock()
+	defer c.prefixMu.Unlock()
+
+	renamed := &Cluster{
+		Owner:               c.Owner,
+		Name:                name,
+		Kubeconfig:          c.Kubeconfig,
+		Context:             c.Context,
+		RestConfig:          c.RestConfig,
+		DynamicClient:       c.DynamicClient,
+		Clientset:           c.Clientset,
+		SubscriptionClient:  c.SubscriptionClient,
+		FunctionClient:      c.FunctionClient,
+		GitRepositoryClient: c.GitRepositoryClient,
+		Cache:               c.Cache,
+		forwarder:           c.forwarder,
+		eppTransport:        c.eppTransport,
+		eventTypePrefix:     c.eventTypePrefix,
+		discoveredPrefix:    c.discoveredPrefix,
+		prefixDiscovered:    c.prefixDiscovered,
+		inCluster:           inCluster,
+		locationKnown:       locationKnown,
+		locationRetry:       locationRetry,
+	}
+	// the requests which still use the old cluster must not open a forwarder for it
+	c.forwarder = nil
+	c.closed = true
+	return renamed
+}
+
+// Close releases the resources held by the cluster
+func (c *Cluster) Close() {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	c.SetForwarder(nil)
+	c.Cache.Close()
+}
+
+// clusterKey identifies a cluster, the names are unique per owner
+type clusterKey struct {
+	owner string
+	name  string
+}
+
+func (c *Cluster) key() clusterKey {
+	return clusterKey{owner: c.Owner, name: c.Name}
+}
+
+// ClusterRegistry is a concurrency safe registry of clusters by owner and name.
+// Every owner only sees its own clusters, the first cluster added by an owner is its default cluster.
+type ClusterRegistry struct {
+	mu           sync.RWMutex
+	clusters     map[clusterKey]*Cluster
+	defaultNames map[string]string // defaultNames maps the owners to the name of their default cluster
+}
+
+// NewClusterRegistry creates an empty cluster registry
+func NewClusterRegistry() *ClusterRegistry {
+	return &ClusterRegistry{
+		clusters:     make(map[clusterKey]*Cluster),
+		defaultNames: make(map[string]string),
+	}
+}
+
+// Add registers a new cluster or returns ErrClusterExists if the owner already uses the name
+func (r *ClusterRegistry) Add(cluster *Cluster) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.clusters[cluster.key()]; ok {
+		return fmt.Errorf("%w: %s", ErrClusterExists, cluster.Name)
+	}
+	r.add(cluster)
+	return nil
+}
+
+// Replace registers the cluster and closes the cluster previously registered with the same owner and name
+func (r *ClusterRegistry) Replace(cluster *Cluster) {
+	r.mu.Lock()
+	previous := r.clusters[cluster.key()]
+	r.add(cluster)
+	r.mu.Unlock()
+
+	if previous != nil && previous != cluster {
+		previous.Close()
+	}
+}
+
+func (r *ClusterRegistry) add(cluster *Cluster) {
+	r.clusters[cluster.key()] = cluster
+	if r.defaultNames[cluster.Owner] == "" {
+		r.defaultNames[cluster.Owner] = cluster.Name
+	}
+}
+
+// Remove unregisters and closes the cluster of the owner or returns ErrClusterNotFound
+func (r *ClusterRegistry) Remove(owner, name string) error {
+	r.mu.Lock()
+	key := clusterKey{owner: owner, name: name}
+	cluster, ok := r.clusters[key]
+	if ok {
+		delete(r.clusters, key)
+		if r.defaultNames[owner] == name {
+			r.setFirstDefault(owner)
+		}
+	}
+	r.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrClusterNotFound, name)
+	}
+	cluster.Close()
+	return nil
+}
+
+// Rename registers the cluster of the owner under a new name, the clients and the EPP forwarder are kept.
+// It returns ErrClusterNotFound or ErrClusterExists if the names do not allow the rename.
+func (r *ClusterRegistry) Rename(owner, name, newName string) (*Cluster, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := clusterKey{owner: owner, name: name}
+	cluster, ok := r.clusters[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrClusterNotFound, name)
+	}
+	newKey := clusterKey{owner: owner, name: newName}
+	if _, ok := r.clusters[newKey]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrClusterExists, newName)
+	}
+
+	renamed := cluster.withName(newName)
+	delete(r.clusters, key)
+	r.clusters[newKey] = renamed
+	if r.defaultNames[owner] == name {
+		r.defaultNames[owner] = newName
+	}
+	return renamed, nil
+}
+
+// setFirstDefault makes the alphabetically first cluster of the owner its default cluster
+func (r *ClusterRegistry) setFirstDefault(owner string) {
+	first := ""
+	for key := range r.clusters {
+		if key.owner == owner && (first == "" || key.name < first) {
+			first = key.name
+		}
+	}
+	if first == "" {
+		delete(r.defaultNames, owner)
+		return
+	}
+	r.defaultNames[owner] = first
+}
+
+// Get returns the cluster of the owner by name, the default cluster is returned for an empty name
+func (r *ClusterRegistry) Get(owner, name string) (*Cluster, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if name == "" {
+		name = r.defaultNames[owner]
+	}
+	cluster, ok := r.clusters[clusterKey{owner: owner, name: name}]
+	return cluster, ok
+}
+
+// Names returns the sorted names of the clusters of the owner
+func (r *ClusterRegistry) Names(owner string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := []string{}
+	for key := range r.clusters {
+		if key.owner == owner {
+			names = append(names, key.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close unregisters and closes all clusters
+func (r *ClusterRegistry) Close() {
+	r.mu.Lock()
+	clusters := r.clusters
+	r.clusters = make(map[clusterKey]*Cluster)
+	r.defaultNames = make(map[string]string)
+	r.mu.Unlock()
+
+	for _, cluster := range clusters {
+		cluster.Close()
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/forwarder"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newTestCluster returns a cluster of the anonymous user with a forwarder which counts its Close calls
+func newTestCluster(name string, closed *int32) *Cluster {
+	return newTestClusterOf("", name, closed)
+}
+
+func newTestClusterOf(owner, name string, closed *int32) *Cluster {
+	cluster := &Cluster{Owner: owner, Name: name}
+	cluster.SetForwarder(&forwarder.Result{
+		Close: func() { atomic.AddInt32(closed, 1) },
+	})
+	return cluster
+}
+
+func TestClusterRegistryAddGetRemove(t *testing.T) {
+	registry := NewClusterRegistry()
+	var closed int32
+
+	if _, ok := registry.Get("", ""); ok {
+		t.Fatal("expected no default cluster in an empty registry")
+	}
+
+	if err := registry.Add(newTestCluster("dev", &closed)); err != nil {
+		t.Fatalf("failed to add cluster: %v", err)
+	}
+	if err := registry.Add(newTestCluster("prod", &closed)); err != nil {
+		t.Fatalf("failed to add cluster: %v", err)
+	}
+	if err := registry.Add(newTestCluster("dev", &closed)); !errors.Is(err, ErrClusterExists) {
+		t.Fatalf("expected ErrClusterExists, got: %v", err)
+	}
+
+	if cluster, ok := registry.Get("", ""); !ok || cluster.Name != "dev" {
+		t.Fatalf("expected the first added cluster to be the default, got: %v", cluster)
+	}
+	if cluster, ok := registry.Get("", "prod"); !ok || cluster.Name != "prod" {
+		t.Fatalf("expected cluster prod, got: %v", cluster)
+	}
+	if names := registry.Names(""); fmt.Sprint(names) != "[dev prod]" {
+		t.Fatalf("unexpected names: %v", names)
+	}
+
+	if err := registry.Remove("", "dev"); err != nil {
+		t.Fatalf("failed to remove cluster: %v", err)
+	}
+	if closed != 1 {
+		t.Fatalf("expected the removed cluster to be closed, closed %d times", closed)
+	}
+	if err := registry.Remove("", "dev"); !errors.Is(err, ErrClusterNotFound) {
+		t.Fatalf("expected ErrClusterNotFound, got: %v", err)
+	}
+	if cluster, ok := registry.Get("", ""); !ok || cluster.Name != "prod" {
+		t.Fatalf("expected prod to become the default, got: %v", cluster)
+	}
+}
+
+func TestClusterRegistryReplaceClosesPrevious(t *testing.T) {
+	registry := NewClusterRegistry()
+	var closedOld, closedNew int32
+
+	registry.Replace(newTestCluster("dev", &closedOld))
+	registry.Replace(newTestCluster("dev", &closedNew))
+
+	if closedOld != 1 || closedNew != 0 {
+		t.Fatalf("expected only the replaced cluster to be closed, got old=%d new=%d", closedOld, closedNew)
+	}
+
+	registry.Close()
+	if closedNew != 1 {
+		t.Fatalf("expected the registry to close its clusters, got %d", closedNew)
+	}
+	if names := registry.Names(""); len(names) != 0 {
+		t.Fatalf("expected an empty registry after close, got: %v", names)
+	}
+}
+
+// TestClusterRegistryConcurrentAccess is meant to be run with the race detector: go test -race
+func TestClusterRegistryConcurrentAccess(t *testing.T) {
+	registry := NewClusterRegistry()
+	var closed int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				name := fmt.Sprintf("cluster-%d", j%4)
+				switch (i + j) % 5 {
+				case 0:
+					_ = registry.Add(newTestCluster(name, &closed))
+				case 1:
+					registry.Replace(newTestCluster(name, &closed))
+				case 2:
+					_ = registry.Remove("", name)
+				case 3:
+					if cluster, ok := registry.Get("", name); ok {
+						cluster.SetForwarder(cluster.Forwarder())
+					}
+				default:
+					registry.Names("")
+					registry.Get("", "")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	registry.Close()
+	if names := registry.Names(""); len(names) != 0 {
+		t.Fatalf("expected an empty registry after close, got: %v", names)
+	}
+}
+
+func TestClusterRegistryRename(t *testing.T) {
+	registry := NewClusterRegistry()
+	var closed int32
+
+	registry.Replace(newTestCluster("dev", &closed))
+	registry.Replace(newTestCluster("prod", &closed))
+
+	if _, err := registry.Rename("", "dev", "prod"); !errors.Is(err, ErrClusterExists) {
+		t.Fatalf("expected ErrClusterExists, got: %v", err)
+	}
+	if _, err := registry.Rename("", "stage", "test"); !errors.Is(err, ErrClusterNotFound) {
+		t.Fatalf("expected ErrClusterNotFound, got: %v", err)
+	}
+
+	renamed, err := registry.Rename("", "dev", "test")
+	if err != nil {
+		t.Fatalf("failed to rename cluster: %v", err)
+	}
+	if renamed.Name != "test" || renamed.Forwarder() == nil {
+		t.Fatalf("expected the renamed cluster to keep its forwarder, got: %+v", renamed)
+	}
+	if cluster, ok := registry.Get("", ""); !ok || cluster != renamed {
+		t.Fatalf("expected the renamed cluster to stay the default, got: %v", cluster)
+	}
+	if names := registry.Names(""); fmt.Sprint(names) != "[prod test]" {
+		t.Fatalf("unexpected names: %v", names)
+	}
+	if closed != 0 {
+		t.Fatalf("expected no cluster to be closed by a rename, closed %d times", closed)
+	}
+}
+
+func TestClusterRenameKeepsLocation(t *testing.T) {
+	defer func(original func() string) { ownClusterUID = original }(ownClusterUID)
+	ownClusterUID = func() string { return "own" }
+
+	clientset := fake.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: metav1.NamespaceSystem, UID: "own"},
+	})
+	registry := NewClusterRegistry()
+	var closed int32
+	cluster := newTestCluster("dev", &closed)
+	cluster.Clientset = clientset
+	registry.Replace(cluster)
+
+	// the location may be looked up while the cluster is renamed
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		cluster.InCluster(context.Background())
+	}()
+	renamed, err := registry.Rename("", "dev", "test")
+	if err != nil {
+		t.Fatalf("failed to rename cluster: %v", err)
+	}
+	wg.Wait()
+
+	if !renamed.InCluster(context.Background()) {
+		t.Fatal("expected the renamed cluster to run the backend")
+	}
+	if actions := len(clientset.Actions()); actions > 2 {
+		t.Fatalf("expected the location to be looked up at most once per cluster, got %d lookups", actions)
+	}
+}
+
+func TestClusterRenameDuringLocationLookup(t *testing.T) {
+	defer func(original func() string) { ownClusterUID = original }(ownClusterUID)
+	ownClusterUID = func() string { return "own" }
+
+	// the lookup of the location blocks until it is released
+	clientset := fake.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: metav1.NamespaceSystem, UID: "own"},
+	})
+	lookup, release := make(chan struct{}), make(chan struct{})
+	clientset.PrependReactor("get", "namespaces", func(k8stesting.Action) (bool, runtime.Object, error) {
+		close(lookup)
+		<-release
+		return false, nil, nil
+	})
+	registry := NewClusterRegistry()
+	var closed int32
+	cluster := newTestCluster("dev", &closed)
+	cluster.Clientset = clientset
+	registry.Replace(cluster)
+
+	located := make(chan bool, 1)
+	go func() { located <- cluster.InCluster(context.Background()) }()
+	<-lookup
+
+	renamed := make(chan error, 1)
+	go func() {
+		_, err := registry.Rename("", "dev", "test")
+		renamed <- err
+	}()
+	select {
+	case err := <-renamed:
+		if err != nil {
+			t.Fatalf("failed to rename cluster: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("expected the rename not to wait for the location lookup")
+	}
+	if _, ok := registry.Get("", "test"); !ok {
+		t.Fatal("expected the cluster to be renamed")
+	}
+
+	close(release)
+	if !<-located {
+		t.Fatal("expected the cluster to run the backend")
+	}
+}
+
+func TestClusterForwarderAfterClose(t *testing.T) {
+	registry := NewClusterRegistry()
+	var closed int32
+
+	old := newTestCluster("dev", &closed)
+	registry.Replace(old)
+	if _, err := registry.Rename("", "dev", "test"); err != nil {
+		t.Fatalf("failed to rename cluster: %v", err)
+	}
+
+	// a request which still holds the old cluster reopens the forwarder
+	old.SetForwarder(&forwarder.Result{Close: func() { atomic.AddInt32(&closed, 1) }})
+	if old.Forwarder() != nil || closed != 1 {
+		t.Fatalf("expected the forwarder of a renamed cluster to be closed, closed %d times", closed)
+	}
+
+	if err := registry.Remove("", "test"); err != nil {
+		t.Fatalf("failed to remove cluster: %v", err)
+	}
+	if closed != 2 {
+		t.Fatalf("expected the forwarder of the removed cluster to be closed, closed %d times", closed)
+	}
+}
+
+const multiContextKubeconfig = `apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com
+- name: prod
+  cluster:
+    server: https://prod.example.com
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: admin
+- name: prod
+  context:
+    cluster: prod
+    user: admin
+users:
+- name: admin
+  user:
+    token: secret
+`
+
+func TestNewClusterContext(t *testing.T) {
+	tests := []struct {
+		context     string
+		wantContext string
+		wantHost    string
+	}{
+		{context: "", wantContext: "dev", wantHost: "https://dev.example.com"},
+		{context: "prod", wantContext: "prod", wantHost: "https://prod.example.com"},
+	}
+
+	for _, tc := range tests {
+		cluster, err := NewCluster("", "test", multiContextKubeconfig, tc.context)
+		if err != nil {
+			t.Fatalf("failed to create cluster for context %q: %v", tc.context, err)
+		}
+		if cluster.Context != tc.wantContext || cluster.RestConfig.Host != tc.wantHost {
+			t.Fatalf("expected context %s with host %s, got %s with %s",
+				tc.wantContext, tc.wantHost, cluster.Context, cluster.RestConfig.Host)
+		}
+	}
+
+	if _, err := NewCluster("", "test", multiContextKubeconfig, "stage"); err == nil {
+		t.Fatal("expected an error for an unknown context")
+	}
+}
+
+func TestClusterRegistryOwnerIsolation(t *testing.T) {
+	registry := NewClusterRegistry()
+	var closed int32
+
+	registry.Replace(newTestClusterOf("alice", "dev", &closed))
+	if err := registry.Add(newTestClusterOf("bob", "dev", &closed)); err != nil {
+		t.Fatalf("expected owners to use the same name, got: %v", err)
+	}
+	registry.Replace(newTestClusterOf("bob", "prod", &closed))
+
+	if names := registry.Names("alice"); fmt.Sprint(names) != "[dev]" {
+		t.Fatalf("expected alice to see only her clusters, got: %v", names)
+	}
+	if _, ok := registry.Get("alice", "prod"); ok {
+		t.Fatal("expected alice not to get the cluster of bob")
+	}
+	if _, ok := registry.Get("", ""); ok {
+		t.Fatal("expected the anonymous user to have no default cluster")
+	}
+	if err := registry.Remove("alice", "prod"); !errors.Is(err, ErrClusterNotFound) {
+		t.Fatalf("expected alice not to remove the cluster of bob, got: %v", err)
+	}
+
+	if err := registry.Remove("bob", "dev"); err != nil {
+		t.Fatalf("failed to remove cluster: %v", err)
+	}
+	if cluster, ok := registry.Get("alice", ""); !ok || cluster.Owner != "alice" || cluster.Name != "dev" {
+		t.Fatalf("expected the cluster of alice to be kept, got: %v", cluster)
+	}
+	if cluster, ok := registry.Get("bob", ""); !ok || cluster.Name != "prod" {
+		t.Fatalf("expected prod to become the default of bob, got: %v", cluster)
+	}
+}
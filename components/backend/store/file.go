@@ -0,0 +1,113 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// fileExtension is the extension of the encrypted record files
+const fileExtension = ".enc"
+
+// FileStore stores every record as an encrypted file in a directory
+type FileStore struct {
+	mu     sync.Mutex
+	dir    string
+	cipher *Cipher
+}
+
+// NewFileStore creates the directory if needed and returns a store for it
+func NewFileStore(dir string, cipher *Cipher) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, cipher: cipher}, nil
+}
+
+// Save writes the record to a temporary file and renames it, so a crash never leaves a partial record
+func (s *FileStore) Save(record Record) error {
+	data, err := s.cipher.seal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(s.dir, ".record-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(record.Owner, record.Name))
+}
+
+// Delete removes the record file
+func (s *FileStore) Delete(owner, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return remove(s.path(owner, name))
+}
+
+// remove removes the file, removing a missing file is not an error
+func remove(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// List decrypts all record files of the directory,
+// a file which can not be read or decrypted is skipped, so that it does not hide the other records
+func (s *FileStore) List() ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []Record{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileExtension) {
+			continue
+		}
+
+		path := filepath.Join(s.dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("skipping stored cluster %s: %v", path, err)
+			continue
+		}
+		record, err := s.cipher.open(data)
+		if err != nil {
+			log.Printf("skipping stored cluster %s: %v", path, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// path returns the file of the record, the record id is hashed to be safe and short enough as a file name
+func (s *FileStore) path(owner, name string) string {
+	hash := sha256.Sum256([]byte(recordID(owner, name)))
+	return filepath.Join(s.dir, hex.EncodeToString(hash[:])+fileExtension)
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// secretLabel marks the Secrets holding the stored records
+	secretLabel = "hackathon2022.kyma-project.io/cluster-store"
+	// secretRecordKey is the Secret data key of the encrypted record
+	secretRecordKey = "record"
+)
+
+// SecretStore stores every record as an encrypted Kubernetes Secret in a namespace
+type SecretStore struct {
+	clientset kubernetes.Interface
+	namespace string
+	cipher    *Cipher
+}
+
+// NewSecretStore returns a store which keeps the records as Secrets in the namespace
+func NewSecretStore(clientset kubernetes.Interface, namespace string, cipher *Cipher) *SecretStore {
+	return &SecretStore{clientset: clientset, namespace: namespace, cipher: cipher}
+}
+
+// Save creates or updates the Secret of the record
+func (s *SecretStore) Save(record Record) error {
+	data, err := s.cipher.seal(record)
+	if err != nil {
+		return err
+	}
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName(record.Owner, record.Name),
+			Namespace: s.namespace,
+			Labels:    map[string]string{secretLabel: "true"},
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{secretRecordKey: data},
+	}
+
+	secrets := s.clientset.CoreV1().Secrets(s.namespace)
+	_, err = secrets.Update(context.Background(), secret, metav1.UpdateOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(context.Background(), secret, metav1.CreateOptions{})
+	}
+	return err
+}
+
+// Delete removes the Secret of the record
+func (s *SecretStore) Delete(owner, name string) error {
+	return s.delete(secretName(owner, name))
+}
+
+// delete removes the Secret, deleting a missing Secret is not an error
+func (s *SecretStore) delete(name string) error {
+	err := s.clientset.CoreV1().Secrets(s.namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// List decrypts the records of all labeled Secrets in the namespace,
+// a Secret which can not be decrypted is skipped, so that it does not hide the other records
+func (s *SecretStore) List() ([]Record, error) {
+	secrets, err := s.clientset.CoreV1().Secrets(s.namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: secretLabel + "=true",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records := []Record{}
+	for _, secret := range secrets.Items {
+		record, err := s.cipher.open(secret.Data[secretRecordKey])
+		if err != nil {
+			log.Printf("skipping stored cluster in Secret %s/%s: %v", secret.Namespace, secret.Name, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// secretName derives a valid Secret name from the owner and the cluster name
+func secretName(owner, name string) string {
+	hash := sha256.Sum256([]byte(recordID(owner, name)))
+	return "cluster-" + hex.EncodeToString(hash[:10])
+}
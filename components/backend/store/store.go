@@ -0,0 +1,109 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// KeySize is the size of the AES-256 key used to encrypt the stored records
+const KeySize = 32
+
+// ErrInvalidKey is returned when the encryption key is not a base64 encoded 32 bytes key
+var ErrInvalidKey = errors.New("encryption key must be 32 base64 encoded bytes")
+
+// Record is a persisted cluster registration
+type Record struct {
+	Owner      string `json:"owner,omitempty"`
+	Name       string `json:"name"`
+	Kubeconfig string `json:"kubeconfig"`
+	Context    string `json:"context,omitempty"`
+
+	EventTypePrefix string `json:"eventTypePrefix,omitempty"` // EventTypePrefix overrides the prefix configured in the cluster
+	EPPTransport    string `json:"eppTransport,omitempty"`    // EPPTransport overrides the transport to the publisher proxy
+}
+
+// Store persists the registered clusters, the records are encrypted at rest.
+// The records are identified by their owner and name.
+type Store interface {
+	// Save creates or replaces the record with the same owner and name
+	Save(record Record) error
+	// Delete removes the record, deleting a missing record is not an error
+	Delete(owner, name string) error
+	// List returns all stored records
+	List() ([]Record, error)
+}
+
+// Cipher encrypts and decrypts records with AES-GCM
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher from a base64 encoded 32 bytes key
+func NewCipher(encodedKey string) (*Cipher, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt returns the nonce followed by the sealed plaintext
+func (c *Cipher) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt opens the data created by Encrypt
+func (c *Cipher) Decrypt(data []byte) ([]byte, error) {
+	nonceSize := c.aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+	return c.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+}
+
+// recordID returns the unique id of a record derived from its owner and name.
+// The tuple is JSON encoded, so that an owner or name containing a separator can not collide with another record.
+func recordID(owner, name string) string {
+	// encoding a slice of strings never fails
+	data, _ := json.Marshal([]string{owner, name})
+	return string(data)
+}
+
+// seal encrypts the JSON encoded record
+func (c *Cipher) seal(record Record) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return c.Encrypt(data)
+}
+
+// open decrypts a record sealed by seal
+func (c *Cipher) open(data []byte) (Record, error) {
+	var record Record
+	plaintext, err := c.Decrypt(data)
+	if err != nil {
+		return record, err
+	}
+	err = json.Unmarshal(plaintext, &record)
+	return record, err
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestCipher(t *testing.T) *Cipher {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := NewCipher(base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		t.Fatalf("failed to create cipher: %v", err)
+	}
+	return cipher
+}
+
+func TestNewCipherInvalidKey(t *testing.T) {
+	for _, key := range []string{"", "not base64", base64.StdEncoding.EncodeToString([]byte("too short"))} {
+		if _, err := NewCipher(key); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("expected ErrInvalidKey for key %q, got: %v", key, err)
+		}
+	}
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	cipher := newTestCipher(t)
+	plaintext := []byte("users:\n- name: admin\n  user:\n    token: secret\n")
+
+	data, err := cipher.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	if bytes.Contains(data, []byte("secret")) {
+		t.Fatal("expected the plaintext not to be readable from the encrypted data")
+	}
+
+	decrypted, err := cipher.Decrypt(data)
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("expected the plaintext back, got %q, %v", decrypted, err)
+	}
+
+	if _, err := newTestCipher(t).Decrypt(data); err == nil {
+		t.Fatal("expected decrypting with another key to fail")
+	}
+}
+
+// testStore saves, lists and deletes records of the store
+func testStore(t *testing.T, s Store) {
+	dev := Record{Owner: "alice", Name: "dev", Kubeconfig: "token: dev-secret", Context: "dev"}
+	otherDev := Record{Owner: "bob", Name: "dev", Kubeconfig: "token: other-secret"}
+	prod := Record{Name: "prod/eu", Kubeconfig: "token: prod-secret"}
+
+	for _, record := range []Record{dev, otherDev, prod, dev} {
+		if err := s.Save(record); err != nil {
+			t.Fatalf("failed to save record %s: %v", record.Name, err)
+		}
+	}
+
+	records, err := s.List()
+	if err != nil {
+		t.Fatalf("failed to list records: %v", err)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return recordID(records[i].Owner, records[i].Name) < recordID(records[j].Owner, records[j].Name)
+	})
+	if !reflect.DeepEqual(records, []Record{prod, dev, otherDev}) {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+
+	if err := s.Delete("alice", "dev"); err != nil {
+		t.Fatalf("failed to delete record: %v", err)
+	}
+	if err := s.Delete("alice", "dev"); err != nil {
+		t.Fatalf("expected deleting a missing record to succeed, got: %v", err)
+	}
+
+	records, err = s.List()
+	if err != nil {
+		t.Fatalf("failed to list records: %v", err)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Owner < records[j].Owner })
+	if !reflect.DeepEqual(records, []Record{prod, otherDev}) {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+}
+
+// testStoreIDs saves records whose owner and name would collide if they were simply joined
+func testStoreIDs(t *testing.T, s Store) {
+	for _, record := range []Record{{Owner: "a/b", Name: "c"}, {Owner: "a", Name: "b/c"}} {
+		if err := s.Save(record); err != nil {
+			t.Fatalf("failed to save record %s: %v", record.Name, err)
+		}
+	}
+	if records, err := s.List(); err != nil || len(records) != 2 {
+		t.Fatalf("expected both records, got %+v, %v", records, err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir, newTestCipher(t))
+	if err != nil {
+		t.Fatalf("failed to create file store: %v", err)
+	}
+
+	testStore(t, s)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("expected two record files, got %v, %v", files, err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "secret") {
+			t.Fatal("expected the kubeconfig to be encrypted at rest")
+		}
+	}
+
+	// a corrupt record does not hide the other records
+	if err := os.WriteFile(filepath.Join(dir, "corrupt"+fileExtension), []byte("not encrypted"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := s.List(); err != nil || len(records) != 2 {
+		t.Fatalf("expected the corrupt record to be skipped, got %+v, %v", records, err)
+	}
+}
+
+func TestFileStoreIDs(t *testing.T) {
+	dir := t.TempDir()
+	cipher := newTestCipher(t)
+	s, err := NewFileStore(dir, cipher)
+	if err != nil {
+		t.Fatalf("failed to create file store: %v", err)
+	}
+	testStoreIDs(t, s)
+
+	// the file name does not grow with the owner, e.g. a long OIDC issuer
+	long := Record{Owner: "oidc:https://" + strings.Repeat("issuer.example.com/", 20) + "#alice", Name: "dev"}
+	if err := s.Save(long); err != nil {
+		t.Fatalf("failed to save a record of a long owner: %v", err)
+	}
+	if records, err := s.List(); err != nil || len(records) != 3 {
+		t.Fatalf("expected the record of the long owner to be listed, got %+v, %v", records, err)
+	}
+	if err := s.Delete(long.Owner, long.Name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSecretStore(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	s := NewSecretStore(clientset, "kyma-system", newTestCipher(t))
+
+	testStore(t, s)
+
+	// a Secret encrypted with another key does not hide the other records
+	other := NewSecretStore(clientset, "kyma-system", newTestCipher(t))
+	if err := other.Save(Record{Name: "foreign", Kubeconfig: "token: foreign-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := s.List(); err != nil || len(records) != 2 {
+		t.Fatalf("expected the undecryptable record to be skipped, got %+v, %v", records, err)
+	}
+}
+
+func TestSecretStoreIDs(t *testing.T) {
+	testStoreIDs(t, NewSecretStore(fake.NewSimpleClientset(), "kyma-system", newTestCipher(t)))
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	eventingv1alpha1 "github.com/kyma-project/kyma/components/eventing-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultEventTypePrefix is the prefix of the event types built from the app, event and version fields
+const defaultEventTypePrefix = "sap.kyma.custom"
+
+// SubscriptionData is the request body to create or update a subscription.
+// The legacy fields (appName, eventName, eventVersion) build a single filter, the filters field
+// any number of filters. A full spec, e.g. from kubectl get subscription -o json, takes precedence over both.
+type SubscriptionData struct {
+	Sink         string `json:"sink"`
+	AppName      string `json:"appName"`
+	EventName    string `json:"eventName"`
+	EventVersion string `json:"eventVersion"`
+
+	EventSource      string                               `json:"eventSource,omitempty"`     // source of the filter built from the legacy fields
+	EventTypePrefix  string                               `json:"eventTypePrefix,omitempty"` // overrides the prefix of the cluster for the legacy fields
+	Filters          []FilterData                         `json:"filters,omitempty"`
+	Dialect          string                               `json:"dialect,omitempty"`
+	Protocol         string                               `json:"protocol,omitempty"`
+	ProtocolSettings *eventingv1alpha1.ProtocolSettings   `json:"protocolsettings,omitempty"`
+	Config           *eventingv1alpha1.SubscriptionConfig `json:"config,omitempty"`
+
+	Metadata *metav1.ObjectMeta                 `json:"metadata,omitempty"` // only the labels and annotations are used
+	Spec     *eventingv1alpha1.SubscriptionSpec `json:"spec,omitempty"`
+}
+
+// FilterData is an exact match filter on the event source and the full event type
+type FilterData struct {
+	EventSource string `json:"eventSource"`
+	EventType   string `json:"eventType"`
+}
+
+// usesEventTypeSegments returns true if the request body builds a filter from the app, event and version fields
+func (data SubscriptionData) usesEventTypeSegments() bool {
+	return data.Spec == nil && (data.AppName != "" || data.EventName != "" || data.EventVersion != "")
+}
+
+// subscriptionEventTypePrefix returns the prefix for the type built from the app, event and version fields:
+// the prefix of the request body, otherwise the prefix of the cluster
+func subscriptionEventTypePrefix(ctx context.Context, cluster *Cluster, data SubscriptionData) (string, error) {
+	if !data.usesEventTypeSegments() {
+		return "", nil
+	}
+	if data.EventTypePrefix != "" {
+		return data.EventTypePrefix, nil
+	}
+
+	prefix, err := cluster.EventTypePrefix(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the event type prefix of the cluster, set eventTypePrefix to override it: %w", err)
+	}
+	return prefix, nil
+}
+
+// buildSubscription returns the subscription described by the request body,
+// the prefix is used for the type built from the app, event and version fields
+func buildSubscription(name, namespace string, data SubscriptionData, prefix string) (*eventingv1alpha1.Subscription, error) {
+	spec, err := buildSubscriptionSpec(data, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	newSub := &eventingv1alpha1.Subscription{Spec: *spec}
+	newSub.Kind = "Subscription"
+	newSub.APIVersion = eventingv1alpha1.GroupVersion.String()
+	newSub.Name = name
+	newSub.Namespace = namespace
+	if data.Metadata != nil {
+		newSub.Labels = data.Metadata.Labels
+		newSub.Annotations = data.Metadata.Annotations
+	}
+
+	return newSub, nil
+}
+
+func buildSubscriptionSpec(data SubscriptionData, prefix string) (*eventingv1alpha1.SubscriptionSpec, error) {
+	if data.Spec != nil {
+		spec := data.Spec.DeepCopy()
+		// the ID is read-only and assigned by the eventing backend
+		spec.ID = ""
+		if spec.Sink == "" {
+			return nil, fmt.Errorf("spec.sink must not be empty")
+		}
+		if spec.Filter == nil || len(spec.Filter.Filters) == 0 {
+			return nil, fmt.Errorf("spec.filter.filters must not be empty")
+		}
+		return spec, nil
+	}
+
+	if data.Sink == "" {
+		return nil, fmt.Errorf("sink must not be empty")
+	}
+
+	filters := data.Filters
+	if data.usesEventTypeSegments() {
+		if prefix == "" {
+			prefix = defaultEventTypePrefix
+		}
+		if err := validateEventTypePrefix(prefix); err != nil {
+			return nil, err
+		}
+		if err := validateEventTypeSegments(data.AppName, data.EventName, data.EventVersion); err != nil {
+			return nil, err
+		}
+		filters = append(filters, FilterData{
+			EventSource: data.EventSource,
+			EventType:   fmt.Sprintf("%s.%s.%s.%s", prefix, data.AppName, data.EventName, data.EventVersion),
+		})
+	}
+	if len(filters) == 0 {
+		return nil, fmt.Errorf("at least one filter or the appName, eventName and eventVersion must be given")
+	}
+
+	spec := &eventingv1alpha1.SubscriptionSpec{
+		Sink:             data.Sink,
+		Protocol:         data.Protocol,
+		ProtocolSettings: data.ProtocolSettings,
+		Config:           data.Config,
+		Filter:           &eventingv1alpha1.BEBFilters{Dialect: data.Dialect},
+	}
+	for _, filter := range filters {
+		if filter.EventType == "" {
+			return nil, fmt.Errorf("the eventType of a filter must not be empty")
+		}
+
+		eventFilter := &eventingv1alpha1.BEBFilter{
+			EventSource: &eventingv1alpha1.Filter{
+				Property: "source",
+				Type:     "exact",
+				Value:    filter.EventSource,
+			},
+			EventType: &eventingv1alpha1.Filter{
+				Property: "type",
+				Type:     "exact",
+				Value:    filter.EventType,
+			},
+		}
+		spec.Filter.Filters = append(spec.Filter.Filters, eventFilter)
+	}
+
+	return spec, nil
+}
+
+const (
+	// maxSubscriptionWait limits the wait query parameter of the create and update endpoints
+	maxSubscriptionWait = 5 * time.Minute
+	// subscriptionPollInterval is the interval to poll a subscription until it is ready
+	subscriptionPollInterval = time.Second
+)
+
+// SubscriptionStatusSummary summarizes the status of a subscription
+type SubscriptionStatusSummary struct {
+	Name             string             `json:"name"`
+	Namespace        string             `json:"namespace"`
+	Ready            bool               `json:"ready"`
+	Conditions       []ConditionSummary `json:"conditions"`
+	CleanEventTypes  []string           `json:"cleanEventTypes"`
+	APIRule          *APIRuleSummary    `json:"apiRule,omitempty"`
+	ExternalSink     string             `json:"externalSink,omitempty"`
+	FailedActivation string             `json:"failedActivation,omitempty"`
+	FailingCondition *ConditionSummary  `json:"failingCondition,omitempty"` // the first condition which is not true
+}
+
+// ConditionSummary is a status condition of a subscription
+type ConditionSummary struct {
+	Type               string      `json:"type"`
+	Status             string      `json:"status"`
+	Reason             string      `json:"reason,omitempty"`
+	Message            string      `json:"message,omitempty"`
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// APIRuleSummary is the status of the APIRule which exposes the sink of a subscription
+type APIRuleSummary struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// summarizeSubscriptionStatus returns the status summary of the subscription
+func summarizeSubscriptionStatus(sub *eventingv1alpha1.Subscription) *SubscriptionStatusSummary {
+	summary := &SubscriptionStatusSummary{
+		Name:             sub.Name,
+		Namespace:        sub.Namespace,
+		Ready:            sub.Status.Ready,
+		Conditions:       []ConditionSummary{},
+		CleanEventTypes:  sub.Status.CleanEventTypes,
+		ExternalSink:     sub.Status.ExternalSink,
+		FailedActivation: sub.Status.FailedActivation,
+	}
+	if summary.CleanEventTypes == nil {
+		summary.CleanEventTypes = []string{}
+	}
+
+	for _, condition := range sub.Status.Conditions {
+		conditionSummary := ConditionSummary{
+			Type:               string(condition.Type),
+			Status:             string(condition.Status),
+			Reason:             string(condition.Reason),
+			Message:            condition.Message,
+			LastTransitionTime: condition.LastTransitionTime,
+		}
+		summary.Conditions = append(summary.Conditions, conditionSummary)
+
+		if condition.Status != corev1.ConditionTrue && summary.FailingCondition == nil {
+			failing := conditionSummary
+			summary.FailingCondition = &failing
+		}
+	}
+
+	if sub.Status.APIRuleName != "" {
+		summary.APIRule = &APIRuleSummary{
+			Name:   sub.Status.APIRuleName,
+			Status: string(sub.Status.GetConditionAPIRuleStatus()),
+		}
+	}
+
+	return summary
+}
+
+// waitParam returns the duration of the wait query parameter, zero means not to wait
+func waitParam(r *http.Request) (time.Duration, error) {
+	v := r.URL.Query().Get("wait")
+	if v == "" {
+		return 0, nil
+	}
+
+	wait, err := time.ParseDuration(v)
+	if err != nil || wait < 0 {
+		return 0, fmt.Errorf("invalid wait duration %q, expected e.g. 30s", v)
+	}
+	if wait > maxSubscriptionWait {
+		return 0, fmt.Errorf("wait duration %s exceeds the maximum of %s", wait, maxSubscriptionWait)
+	}
+	return wait, nil
+}
+
+// respondSubscriptionReady waits until the subscription is ready and responds with its status summary.
+// It responds with the given status if the subscription became ready, otherwise with 202 and the failing condition.
+func respondSubscriptionReady(w http.ResponseWriter, r *http.Request, cluster *Cluster, name, namespace string, wait time.Duration, status int) {
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+
+	sub, err := cluster.SubscriptionClient.WaitUntilReady(ctx, name, namespace, subscriptionPollInterval)
+	if sub == nil {
+		log.Printf("%s %s failed to wait for subscription: %v", r.Method, r.RequestURI, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !sub.Status.Ready {
+		status = http.StatusAccepted
+	}
+
+	data, err := json.Marshal(summarizeSubscriptionStatus(sub))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(status)
+	_, err = w.Write(data)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
+
+func getSubStatus(w http.ResponseWriter, r *http.Request) {
+	// Fetch data from URI
+	namespace := mux.Vars(r)["ns"]
+	name := mux.Vars(r)["name"]
+
+	sub, err := clusterFromContext(r.Context()).SubscriptionClient.GetSub(name, namespace)
+	if apierrors.IsNotFound(err) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(summarizeSubscriptionStatus(sub))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response to user
+	_, err = w.Write(data)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	eventingv1alpha1 "github.com/kyma-project/kyma/components/eventing-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestBuildSubscriptionLegacyFields(t *testing.T) {
+	data := SubscriptionData{
+		Sink:         "http://test.tunas-testing.svc.cluster.local",
+		AppName:      "noapp",
+		EventName:    "order.created",
+		EventVersion: "v1",
+	}
+
+	sub, err := buildSubscription("test", "tunas-testing", data, "")
+	if err != nil {
+		t.Fatalf("failed to build subscription: %v", err)
+	}
+
+	filters := sub.Spec.Filter.Filters
+	if len(filters) != 1 || filters[0].EventType.Value != "sap.kyma.custom.noapp.order.created.v1" || filters[0].EventSource.Value != "" {
+		t.Fatalf("unexpected filters: %+v", filters)
+	}
+	if sub.Name != "test" || sub.Namespace != "tunas-testing" || sub.APIVersion != "eventing.kyma-project.io/v1alpha1" {
+		t.Fatalf("unexpected object meta: %+v", sub.ObjectMeta)
+	}
+}
+
+func TestBuildSubscriptionFilters(t *testing.T) {
+	maxInFlight := eventingv1alpha1.SubscriptionConfig{MaxInFlightMessages: 5}
+	data := SubscriptionData{
+		Sink: "http://test.default.svc.cluster.local",
+		Filters: []FilterData{
+			{EventSource: "commerce", EventType: "sap.kyma.custom.commerce.order.created.v1"},
+			{EventSource: "/default/sap.kyma/tunas", EventType: "com.example.order.shipped.v2"},
+		},
+		AppName:      "noapp",
+		EventName:    "order.created",
+		EventVersion: "v1",
+		EventSource:  "noapp",
+		Config:       &maxInFlight,
+	}
+
+	sub, err := buildSubscription("test", "default", data, "com.example")
+	if err != nil {
+		t.Fatalf("failed to build subscription: %v", err)
+	}
+
+	var types, sources []string
+	for _, filter := range sub.Spec.Filter.Filters {
+		types = append(types, filter.EventType.Value)
+		sources = append(sources, filter.EventSource.Value)
+	}
+	wantTypes := []string{"sap.kyma.custom.commerce.order.created.v1", "com.example.order.shipped.v2", "com.example.noapp.order.created.v1"}
+	wantSources := []string{"commerce", "/default/sap.kyma/tunas", "noapp"}
+	if !reflect.DeepEqual(types, wantTypes) || !reflect.DeepEqual(sources, wantSources) {
+		t.Fatalf("unexpected filters: types %v, sources %v", types, sources)
+	}
+	if sub.Spec.Config == nil || sub.Spec.Config.MaxInFlightMessages != 5 {
+		t.Fatalf("expected the config to be set, got: %+v", sub.Spec.Config)
+	}
+}
+
+func TestBuildSubscriptionRoundTrip(t *testing.T) {
+	// the output of kubectl get subscription -o json
+	kubectlJSON := `{
+		"apiVersion": "eventing.kyma-project.io/v1alpha1",
+		"kind": "Subscription",
+		"metadata": {"name": "test", "namespace": "default", "labels": {"team": "tunas"}, "resourceVersion": "42"},
+		"spec": {
+			"id": "read-only",
+			"protocol": "BEB",
+			"protocolsettings": {"contentMode": "BINARY", "exemptHandshake": true, "qos": "AT_LEAST_ONCE"},
+			"sink": "http://test.default.svc.cluster.local",
+			"filter": {
+				"dialect": "beb",
+				"filters": [{
+					"eventSource": {"property": "source", "type": "exact", "value": "/default/sap.kyma/tunas"},
+					"eventType": {"property": "type", "type": "exact", "value": "sap.kyma.custom.noapp.order.created.v1"}
+				}]
+			},
+			"config": {"maxInFlightMessages": 10}
+		},
+		"status": {"ready": true}
+	}`
+
+	var data SubscriptionData
+	if err := json.Unmarshal([]byte(kubectlJSON), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := buildSubscription("test", "default", data, "")
+	if err != nil {
+		t.Fatalf("failed to build subscription: %v", err)
+	}
+
+	want := data.Spec.DeepCopy()
+	want.ID = ""
+	if !reflect.DeepEqual(sub.Spec, *want) {
+		t.Fatalf("expected the spec to be kept, got: %+v", sub.Spec)
+	}
+	if sub.Labels["team"] != "tunas" || sub.ResourceVersion != "" {
+		t.Fatalf("expected only the labels to be taken from the metadata, got: %+v", sub.ObjectMeta)
+	}
+}
+
+func TestBuildSubscriptionInvalid(t *testing.T) {
+	tests := map[string]SubscriptionData{
+		"no sink":        {AppName: "noapp", EventName: "order.created", EventVersion: "v1"},
+		"no filter":      {Sink: "http://test.default.svc.cluster.local"},
+		"no event type":  {Sink: "http://test.default.svc.cluster.local", Filters: []FilterData{{EventSource: "noapp"}}},
+		"no spec sink":   {Spec: &eventingv1alpha1.SubscriptionSpec{Filter: &eventingv1alpha1.BEBFilters{}}},
+		"dotted app":     {Sink: "http://test.default.svc.cluster.local", AppName: "no.app", EventName: "order.created", EventVersion: "v1"},
+		"empty segment":  {Sink: "http://test.default.svc.cluster.local", AppName: "noapp", EventName: "order..created", EventVersion: "v1"},
+		"trailing dot":   {Sink: "http://test.default.svc.cluster.local", AppName: "noapp", EventName: "order.", EventVersion: "v1"},
+		"dotted version": {Sink: "http://test.default.svc.cluster.local", AppName: "noapp", EventName: "order.created", EventVersion: "v1.0"},
+		"invalid char":   {Sink: "http://test.default.svc.cluster.local", AppName: "no app", EventName: "order.created", EventVersion: "v1"},
+		"invalid prefix": {Sink: "http://test.default.svc.cluster.local", AppName: "noapp", EventName: "order.created", EventVersion: "v1", EventTypePrefix: "sap..kyma"},
+	}
+
+	for name, data := range tests {
+		if _, err := buildSubscription("test", "default", data, data.EventTypePrefix); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSummarizeSubscriptionStatus(t *testing.T) {
+	sub := &eventingv1alpha1.Subscription{}
+	sub.Name = "test"
+	sub.Namespace = "default"
+	sub.Status.APIRuleName = "webhook-abc"
+	sub.Status.Conditions = []eventingv1alpha1.Condition{
+		{Type: eventingv1alpha1.ConditionSubscribed, Status: corev1.ConditionTrue},
+		{Type: eventingv1alpha1.ConditionAPIRuleStatus, Status: corev1.ConditionFalse, Reason: eventingv1alpha1.ConditionReasonAPIRuleStatusNotReady, Message: "not ready"},
+		{Type: eventingv1alpha1.ConditionSubscriptionActive, Status: corev1.ConditionFalse},
+	}
+
+	summary := summarizeSubscriptionStatus(sub)
+
+	if summary.Ready || len(summary.Conditions) != 3 || summary.CleanEventTypes == nil {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if summary.FailingCondition == nil || summary.FailingCondition.Type != string(eventingv1alpha1.ConditionAPIRuleStatus) ||
+		summary.FailingCondition.Message != "not ready" {
+		t.Fatalf("expected the APIRule condition to be failing, got: %+v", summary.FailingCondition)
+	}
+	if summary.APIRule == nil || summary.APIRule.Name != "webhook-abc" || summary.APIRule.Status != "False" {
+		t.Fatalf("unexpected APIRule status: %+v", summary.APIRule)
+	}
+}
+
+func TestWaitParam(t *testing.T) {
+	tests := map[string]struct {
+		query   string
+		want    time.Duration
+		wantErr bool
+	}{
+		"no wait":     {query: "", want: 0},
+		"seconds":     {query: "?wait=30s", want: 30 * time.Second},
+		"invalid":     {query: "?wait=soon", wantErr: true},
+		"negative":    {query: "?wait=-1s", wantErr: true},
+		"exceeds max": {query: "?wait=1h", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		wait, err := waitParam(httptest.NewRequest(http.MethodPost, "/api/default/subs/test"+tc.query, nil))
+		if (err != nil) != tc.wantErr || wait != tc.want {
+			t.Fatalf("%s: expected %s, got %s, %v", name, tc.want, wait, err)
+		}
+	}
+}
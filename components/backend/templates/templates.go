@@ -0,0 +1,236 @@
+package templates
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// builtin holds the templates shipped with the backend, they use the same format as the template files
+//
+//go:embed builtin/*.yaml
+var builtin embed.FS
+
+// ErrInvalidTemplate is returned for a template without name, runtimes or source
+var ErrInvalidTemplate = errors.New("invalid template")
+
+// Template is the boilerplate of a function for one or more runtimes
+type Template struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Runtimes    []string `json:"runtimes"`
+	Source      string   `json:"source"`
+	Deps        string   `json:"deps,omitempty"`
+}
+
+// Validate returns ErrInvalidTemplate if a required field is missing
+func (t Template) Validate() error {
+	switch {
+	case t.Name == "":
+		return fmt.Errorf("%w: name is missing", ErrInvalidTemplate)
+	case len(t.Runtimes) == 0:
+		return fmt.Errorf("%w %s: runtimes are missing", ErrInvalidTemplate, t.Name)
+	case t.Source == "":
+		return fmt.Errorf("%w %s: source is missing", ErrInvalidTemplate, t.Name)
+	}
+	return nil
+}
+
+// Parse parses a template from YAML or JSON
+func Parse(data []byte) (Template, error) {
+	var template Template
+	if err := yaml.UnmarshalStrict(data, &template); err != nil {
+		return Template{}, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	return template, template.Validate()
+}
+
+// Builtin returns the templates shipped with the backend
+func Builtin() []Template {
+	files, err := builtin.ReadDir("builtin")
+	if err != nil {
+		panic(err)
+	}
+
+	templates := make([]Template, 0, len(files))
+	for _, file := range files {
+		data, err := builtin.ReadFile("builtin/" + file.Name())
+		if err != nil {
+			panic(err)
+		}
+		template, err := Parse(data)
+		if err != nil {
+			panic(fmt.Sprintf("builtin template %s: %v", file.Name(), err))
+		}
+		templates = append(templates, template)
+	}
+	return templates
+}
+
+// LoadDir loads the templates of the .yaml, .yml and .json files in the directory
+func LoadDir(dir string) ([]Template, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var templates []Template
+	for _, file := range files {
+		switch filepath.Ext(file.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		if file.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		template, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name(), err)
+		}
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
+// LoadConfigMap loads the templates of the ConfigMap, every key holds a template in YAML or JSON
+func LoadConfigMap(ctx context.Context, clientset kubernetes.Interface, namespace, name string) ([]Template, error) {
+	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(configMap.Data))
+	for key := range configMap.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	templates := make([]Template, 0, len(keys))
+	for _, key := range keys {
+		template, err := Parse([]byte(configMap.Data[key]))
+		if err != nil {
+			return nil, fmt.Errorf("ConfigMap %s/%s key %s: %w", namespace, name, key, err)
+		}
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
+type templateKey struct {
+	name    string
+	runtime string
+}
+
+// Catalog is a concurrency safe registry of templates by name and runtime
+type Catalog struct {
+	mu        sync.RWMutex
+	templates map[templateKey]*Template
+}
+
+// NewCatalog creates a catalog of the templates
+func NewCatalog(templates ...Template) (*Catalog, error) {
+	c := &Catalog{templates: make(map[templateKey]*Template)}
+	return c, c.Add(templates...)
+}
+
+// Add registers the templates for their runtimes, a template replaces the template
+// with the same name for its runtimes. Nothing is added if a template is invalid.
+func (c *Catalog) Add(templates ...Template) error {
+	for _, template := range templates {
+		if err := template.Validate(); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range templates {
+		template := templates[i]
+		for _, runtime := range template.Runtimes {
+			c.templates[templateKey{name: template.Name, runtime: runtime}] = &template
+		}
+	}
+	return nil
+}
+
+// Get returns the template by name for the runtime
+func (c *Catalog) Get(name, runtime string) (Template, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	template, ok := c.templates[templateKey{name: name, runtime: runtime}]
+	if !ok {
+		return Template{}, false
+	}
+	return *template, true
+}
+
+// Runtimes returns the sorted runtimes with a template of the name
+func (c *Catalog) Runtimes(name string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var runtimes []string
+	for key := range c.templates {
+		if key.name == name {
+			runtimes = append(runtimes, key.runtime)
+		}
+	}
+	sort.Strings(runtimes)
+	return runtimes
+}
+
+// List returns the templates sorted by name, only the templates of the runtime are returned if it is not empty.
+// The runtimes of a template which were replaced by another template are not listed.
+func (c *Catalog) List(runtime string) []Template {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	runtimes := make(map[*Template][]string)
+	for key, template := range c.templates {
+		runtimes[template] = append(runtimes[template], key.runtime)
+	}
+
+	templates := []Template{}
+	for template, active := range runtimes {
+		if runtime != "" && !contains(active, runtime) {
+			continue
+		}
+		sort.Strings(active)
+		listed := *template
+		listed.Runtimes = active
+		templates = append(templates, listed)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Name != templates[j].Name {
+			return templates[i].Name < templates[j].Name
+		}
+		return templates[i].Runtimes[0] < templates[j].Runtimes[0]
+	})
+	return templates
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const teamTemplate = `name: slack-notify
+description: Posts the event to Slack
+runtimes: [nodejs16]
+source: |
+  module.exports = { main: async (event) => event.data }
+`
+
+func TestBuiltin(t *testing.T) {
+	catalog, err := NewCatalog(Builtin()...)
+	if err != nil {
+		t.Fatalf("invalid builtin templates: %v", err)
+	}
+
+	for _, name := range []string{"hello-world", "echo-event", "forward-http", "transform-republish"} {
+		for _, runtime := range []string{"nodejs14", "nodejs16", "python39"} {
+			if _, ok := catalog.Get(name, runtime); !ok {
+				t.Fatalf("expected a builtin template %s for %s", name, runtime)
+			}
+		}
+	}
+
+	if templates := catalog.List("python39"); len(templates) != 4 || templates[0].Name != "echo-event" {
+		t.Fatalf("expected the 4 python templates sorted by name, got: %+v", templates)
+	}
+}
+
+func TestCatalogReplace(t *testing.T) {
+	catalog, err := NewCatalog(Template{Name: "echo", Runtimes: []string{"nodejs14", "nodejs16"}, Source: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := catalog.Add(Template{Name: "echo", Runtimes: []string{"nodejs16"}, Source: "v2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if template, _ := catalog.Get("echo", "nodejs14"); template.Source != "v1" {
+		t.Fatalf("expected nodejs14 to keep the first template, got: %+v", template)
+	}
+	if template, _ := catalog.Get("echo", "nodejs16"); template.Source != "v2" {
+		t.Fatalf("expected nodejs16 to use the replacing template, got: %+v", template)
+	}
+
+	templates := catalog.List("")
+	if len(templates) != 2 || len(templates[0].Runtimes) != 1 || len(templates[1].Runtimes) != 1 {
+		t.Fatalf("expected the replaced runtime not to be listed, got: %+v", templates)
+	}
+	if runtimes := catalog.Runtimes("echo"); len(runtimes) != 2 || runtimes[0] != "nodejs14" {
+		t.Fatalf("unexpected runtimes: %v", runtimes)
+	}
+
+	if err := catalog.Add(Template{Name: "invalid", Runtimes: []string{"nodejs16"}}); !errors.Is(err, ErrInvalidTemplate) {
+		t.Fatalf("expected ErrInvalidTemplate, got: %v", err)
+	}
+	if _, ok := catalog.Get("invalid", "nodejs16"); ok {
+		t.Fatal("expected an invalid template not to be added")
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "slack-notify.yaml"), []byte(teamTemplate), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Templates"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to load templates: %v", err)
+	}
+	if len(templates) != 1 || templates[0].Name != "slack-notify" || templates[0].Runtimes[0] != "nodejs16" {
+		t.Fatalf("unexpected templates: %+v", templates)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"name": "broken", "sauce": ""}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDir(dir); !errors.Is(err, ErrInvalidTemplate) {
+		t.Fatalf("expected ErrInvalidTemplate, got: %v", err)
+	}
+}
+
+func TestLoadConfigMap(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "function-templates", Namespace: "tunas"},
+		Data:       map[string]string{"slack-notify.yaml": teamTemplate},
+	})
+
+	templates, err := LoadConfigMap(context.Background(), clientset, "tunas", "function-templates")
+	if err != nil {
+		t.Fatalf("failed to load templates: %v", err)
+	}
+	if len(templates) != 1 || templates[0].Name != "slack-notify" {
+		t.Fatalf("unexpected templates: %+v", templates)
+	}
+
+	if _, err := LoadConfigMap(context.Background(), clientset, "tunas", "missing"); err == nil {
+		t.Fatal("expected an error for a missing ConfigMap")
+	}
+}
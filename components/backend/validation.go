@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/function"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/subscription"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Steps of registering a kubeconfig, a ValidationError names the step which failed
+const (
+	StepParse       = "parse"
+	StepRestConfig  = "rest-config"
+	StepClients     = "clients"
+	StepDiscovery   = "discovery"
+	StepCRD         = "crd"
+	StepPortForward = "port-forward"
+)
+
+// requiredResources are the Kyma resources which must be served by a registered cluster
+var requiredResources = []schema.GroupVersionResource{
+	subscription.GroupVersionResource(),
+	function.GroupVersionResource(),
+}
+
+// checkCredentials rejects credentials of the context which would run a command or read a file on the backend host,
+// e.g. a tokenFile with the service account token of the backend. Only the inline token, *-data and basic auth fields are accepted.
+func checkCredentials(config *clientcmdapi.Config, context string) error {
+	kubeContext := config.Contexts[context]
+	if authInfo, ok := config.AuthInfos[kubeContext.AuthInfo]; ok {
+		switch {
+		case authInfo.Exec != nil:
+			return fmt.Errorf("user %s: exec credential plugins are not allowed", kubeContext.AuthInfo)
+		case authInfo.AuthProvider != nil:
+			return fmt.Errorf("user %s: auth providers are not allowed", kubeContext.AuthInfo)
+		case authInfo.TokenFile != "":
+			return fmt.Errorf("user %s: tokenFile is not allowed, use token", kubeContext.AuthInfo)
+		case authInfo.ClientCertificate != "":
+			return fmt.Errorf("user %s: client-certificate is not allowed, use client-certificate-data", kubeContext.AuthInfo)
+		case authInfo.ClientKey != "":
+			return fmt.Errorf("user %s: client-key is not allowed, use client-key-data", kubeContext.AuthInfo)
+		}
+	}
+	if cluster, ok := config.Clusters[kubeContext.Cluster]; ok && cluster.CertificateAuthority != "" {
+		return fmt.Errorf("cluster %s: certificate-authority is not allowed, use certificate-authority-data", kubeContext.Cluster)
+	}
+	return nil
+}
+
+// ValidationError reports the step at which a kubeconfig was rejected
+type ValidationError struct {
+	Step    string `json:"step"`
+	Context string `json:"context,omitempty"`
+	Message string `json:"error"`
+}
+
+func newValidationError(step string, err error) *ValidationError {
+	return &ValidationError{Step: step, Message: err.Error()}
+}
+
+func (e *ValidationError) Error() string {
+	if e.Context != "" {
+		return fmt.Sprintf("kubeconfig validation failed at step %s for context %s: %s", e.Step, e.Context, e.Message)
+	}
+	return fmt.Sprintf("kubeconfig validation failed at step %s: %s", e.Step, e.Message)
+}
+
+// withContext sets the kubeconfig context on a ValidationError
+func withContext(err error, context string) error {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) && validationErr.Context == "" {
+		validationErr.Context = context
+	}
+	return err
+}
+
+// validateCluster checks that the API server of the cluster is reachable
+// and serves the Kyma subscription and function CRDs
+func validateCluster(cluster *Cluster) error {
+	restConfig := rest.CopyConfig(cluster.RestConfig)
+	restConfig.Timeout = reachableTimeout
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return newValidationError(StepClients, err)
+	}
+
+	if _, err := discoveryClient.ServerVersion(); err != nil {
+		return newValidationError(StepDiscovery, err)
+	}
+
+	for _, gvr := range requiredResources {
+		crd := gvr.GroupResource().String()
+		resources, err := discoveryClient.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+		if apierrors.IsNotFound(err) {
+			return newValidationError(StepCRD, fmt.Errorf("CRD %s is not installed", crd))
+		}
+		if err != nil {
+			return newValidationError(StepDiscovery, err)
+		}
+
+		found := false
+		for _, resource := range resources.APIResources {
+			if resource.Name == gvr.Resource {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return newValidationError(StepCRD, fmt.Errorf("CRD %s is not served in version %s", crd, gvr.Version))
+		}
+	}
+
+	return nil
+}
+
+// writeValidationError responds with the structured ValidationError or the plain error
+func writeValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := json.Marshal(validationErr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusBadRequest)
+	_, err = w.Write(data)
+	if err != nil {
+		log.Printf("%s %s failed to write response: %v", r.Method, r.RequestURI, err)
+	}
+}
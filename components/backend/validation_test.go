@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/rest"
+)
+
+// newFakeAPIServer serves the discovery endpoints for the given group versions and their resources
+func newFakeAPIServer(t *testing.T, groupVersions map[string][]string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(version.Info{GitVersion: "v1.24.3"})
+	})
+	for groupVersion, resources := range groupVersions {
+		list := metav1.APIResourceList{GroupVersion: groupVersion}
+		for _, resource := range resources {
+			list.APIResources = append(list.APIResources, metav1.APIResource{Name: resource, Namespaced: true})
+		}
+		mux.HandleFunc("/apis/"+groupVersion, func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(list)
+		})
+	}
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestValidateCluster(t *testing.T) {
+	tests := []struct {
+		name          string
+		groupVersions map[string][]string
+		wantStep      string
+	}{
+		{
+			name: "all CRDs are installed",
+			groupVersions: map[string][]string{
+				"eventing.kyma-project.io/v1alpha1":   {"subscriptions"},
+				"serverless.kyma-project.io/v1alpha1": {"functions", "gitrepositories"},
+			},
+		},
+		{
+			name: "function CRD is missing",
+			groupVersions: map[string][]string{
+				"eventing.kyma-project.io/v1alpha1": {"subscriptions"},
+			},
+			wantStep: StepCRD,
+		},
+		{
+			name: "subscription resource is not served",
+			groupVersions: map[string][]string{
+				"eventing.kyma-project.io/v1alpha1":   {"eventingbackends"},
+				"serverless.kyma-project.io/v1alpha1": {"functions"},
+			},
+			wantStep: StepCRD,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			server := newFakeAPIServer(t, tc.groupVersions)
+			cluster := &Cluster{Name: "test", RestConfig: &rest.Config{Host: server.URL}}
+
+			err := validateCluster(cluster)
+			if tc.wantStep == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Step != tc.wantStep {
+				t.Fatalf("expected a validation error at step %s, got: %v", tc.wantStep, err)
+			}
+		})
+	}
+}
+
+func TestValidateClusterUnreachable(t *testing.T) {
+	server := newFakeAPIServer(t, nil)
+	server.Close()
+	cluster := &Cluster{Name: "test", RestConfig: &rest.Config{Host: server.URL}}
+
+	var validationErr *ValidationError
+	if err := validateCluster(cluster); !errors.As(err, &validationErr) || validationErr.Step != StepDiscovery {
+		t.Fatalf("expected a validation error at step %s, got: %v", StepDiscovery, err)
+	}
+}
+
+func TestNewClusterInvalidKubeconfig(t *testing.T) {
+	var validationErr *ValidationError
+	if _, err := NewCluster("", "test", "not: [a kubeconfig", ""); !errors.As(err, &validationErr) || validationErr.Step != StepParse {
+		t.Fatalf("expected a validation error at step %s, got: %v", StepParse, err)
+	}
+}
+
+func TestNewClusterRejectsHostCredentials(t *testing.T) {
+	kubeconfig := testKubeconfig("https://api.example.com")
+	if _, err := NewCluster("", "test", kubeconfig, ""); err != nil {
+		t.Fatalf("expected the inline token to be accepted, got: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		replace string
+		with    string
+	}{
+		{name: "exec plugin", replace: "    token: test-token", with: "    exec:\n      apiVersion: client.authentication.k8s.io/v1beta1\n      command: /bin/sh"},
+		{name: "auth provider", replace: "    token: test-token", with: "    auth-provider:\n      name: oidc"},
+		{name: "token file", replace: "    token: test-token", with: "    tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token"},
+		{name: "client certificate", replace: "    token: test-token", with: "    client-certificate: /etc/ssl/client.crt"},
+		{name: "client key", replace: "    token: test-token", with: "    client-key: /etc/ssl/client.key"},
+		{name: "certificate authority", replace: "    server:", with: "    certificate-authority: /var/run/secrets/kubernetes.io/serviceaccount/ca.crt\n    server:"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var validationErr *ValidationError
+			_, err := NewCluster("", "test", strings.Replace(kubeconfig, tc.replace, tc.with, 1), "")
+			if !errors.As(err, &validationErr) || validationErr.Step != StepParse || !strings.Contains(err.Error(), "not allowed") {
+				t.Fatalf("expected a validation error at step %s, got: %v", StepParse, err)
+			}
+		})
+	}
+}
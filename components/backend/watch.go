@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/function"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/informer"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/subscription"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// watchHeartbeatInterval is the interval of the keep-alive comments sent to idle watch streams
+	watchHeartbeatInterval = 30 * time.Second
+	// watchBufferSize is the number of events buffered per watch stream, a stream which falls further behind is closed
+	watchBufferSize = 100
+	// maxInformerWatches is the number of watch streams per cluster which start their own informers
+	// because the informer cache of the cluster failed, e.g. for a namespace-scoped kubeconfig
+	maxInformerWatches = 10
+)
+
+// watch event types
+const (
+	WatchAdded    = "ADDED"
+	WatchModified = "MODIFIED"
+	WatchDeleted  = "DELETED"
+)
+
+// watchKinds maps the kinds of the kinds query parameter to their resources
+var watchKinds = map[string]schema.GroupVersionResource{
+	"subs":  subscription.GroupVersionResource(),
+	"funcs": function.GroupVersionResource(),
+}
+
+// WatchEvent is a change of a subscription or function pushed to the watch stream
+type WatchEvent struct {
+	Type   string                     `json:"type"`
+	Kind   string                     `json:"kind"` // Kind is subs or funcs
+	Object *unstructured.Unstructured `json:"object,omitempty"`
+}
+
+// watchResources streams the changes of the subscriptions and functions of a namespace as Server-Sent Events.
+// The current resources are sent as ADDED events, then every change is pushed until the client disconnects.
+// The streams share the informer cache of the cluster, a stream starts its own informers only if the cache failed.
+func watchResources(w http.ResponseWriter, r *http.Request) {
+	namespace := "default"
+	// Fetch namespace and kinds info from the query parameters
+	v := r.URL.Query()
+	if v.Get("ns") == "-A" {
+		namespace = ""
+	} else if v.Get("ns") != "" {
+		namespace = v.Get("ns")
+	}
+
+	kinds, err := watchKindsParam(v.Get("kinds"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	cluster := clusterFromContext(r.Context())
+	events := make(chan WatchEvent, watchBufferSize)
+	done := r.Context().Done()
+	overflowed := make(chan struct{})
+	var overflowOnce sync.Once
+	overflow := func() {
+		overflowOnce.Do(func() { close(overflowed) })
+	}
+
+	// Register the watches with the informer cache of the cluster, they are stopped when the client disconnects
+	current, stop, err := watchCache(cluster.Cache, namespace, kinds, events, overflow)
+	if err != nil {
+		if atomic.AddInt32(&cluster.informerWatches, 1) > maxInformerWatches {
+			atomic.AddInt32(&cluster.informerWatches, -1)
+			log.Printf("%s %s failed: %v", r.Method, r.RequestURI, err)
+			http.Error(w, "too many watches of the cluster", http.StatusServiceUnavailable)
+			return
+		}
+		defer atomic.AddInt32(&cluster.informerWatches, -1)
+
+		// Start the informers of the requested kinds, they are stopped when the client disconnects
+		log.Printf("%s %s starts its own informers: %v", r.Method, r.RequestURI, err)
+		watchInformers(cluster.DynamicClient, namespace, kinds, events, done)
+		stop = func() {}
+	}
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for _, event := range current {
+		if err := writeWatchEvent(w, event); err != nil {
+			log.Printf("%s %s failed to write event: %v", r.Method, r.RequestURI, err)
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(watchHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+		select {
+		case <-done:
+			return
+		case <-overflowed:
+			// the client reconnects and gets the current resources again
+			log.Printf("%s %s fell behind by more than %d events, closing the stream", r.Method, r.RequestURI, watchBufferSize)
+			return
+		case event := <-events:
+			err = writeWatchEvent(w, event)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err != nil {
+			log.Printf("%s %s failed to write event: %v", r.Method, r.RequestURI, err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// watchCache registers watches of the kinds with the shared informer cache and returns the current resources as ADDED events.
+// The informers must not be blocked by a slow stream, so overflow is called instead if the events are not read in time.
+func watchCache(c *informer.Cache, namespace string, kinds []string, events chan<- WatchEvent, overflow func()) ([]WatchEvent, func(), error) {
+	if c == nil {
+		return nil, nil, errors.New("the cluster has no informer cache")
+	}
+
+	var current []WatchEvent
+	var stops []func()
+	stop := func() {
+		for _, stopKind := range stops {
+			stopKind()
+		}
+	}
+	for _, kind := range kinds {
+		list, stopKind, err := c.Lister(watchKinds[kind]).Watch(namespace, watchHandler(kind, func(event WatchEvent) {
+			select {
+			case events <- event:
+			default:
+				overflow()
+			}
+		}))
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+		stops = append(stops, stopKind)
+
+		for i := range list.Items {
+			current = append(current, WatchEvent{Type: WatchAdded, Kind: kind, Object: &list.Items[i]})
+		}
+	}
+	return current, stop, nil
+}
+
+// watchInformers starts informers of the kinds for a single stream, they send the current resources as ADDED events
+// and are stopped when done is closed
+func watchInformers(client dynamic.Interface, namespace string, kinds []string, events chan<- WatchEvent, done <-chan struct{}) {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, 0, namespace, nil)
+	for _, kind := range kinds {
+		factory.ForResource(watchKinds[kind]).Informer().AddEventHandler(watchHandler(kind, func(event WatchEvent) {
+			select {
+			case events <- event:
+			case <-done:
+			}
+		}))
+	}
+	factory.Start(done)
+}
+
+// watchKindsParam returns the kinds of the comma separated kinds query parameter, all kinds are watched by default
+func watchKindsParam(param string) ([]string, error) {
+	if param == "" {
+		return []string{"subs", "funcs"}, nil
+	}
+
+	var kinds []string
+	for _, kind := range strings.Split(param, ",") {
+		kind = strings.TrimSpace(kind)
+		if _, ok := watchKinds[kind]; !ok {
+			return nil, fmt.Errorf("invalid kind %q, expected subs or funcs", kind)
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+// watchHandler returns an informer event handler which passes the events of the kind to send
+func watchHandler(kind string, send func(event WatchEvent)) cache.ResourceEventHandler {
+	event := func(eventType string, obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		object, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return
+		}
+		send(WatchEvent{Type: eventType, Kind: kind, Object: object})
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			event(WatchAdded, obj)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			event(WatchModified, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			event(WatchDeleted, obj)
+		},
+	}
+}
+
+// writeWatchEvent writes the event in the Server-Sent Events format
+func writeWatchEvent(w http.ResponseWriter, event WatchEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", strings.ToLower(event.Type), data)
+	return err
+}
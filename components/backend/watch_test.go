@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/function"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/gitrepository"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/informer"
+	"github.com/vladislavpaskar/hackathon2022/components/backend/clients/subscription"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newUnstructured(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func newFakeDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		subscription.GroupVersionResource():  "SubscriptionList",
+		function.GroupVersionResource():      "FunctionList",
+		gitrepository.GroupVersionResource(): "GitRepositoryList",
+	}, objects...)
+}
+
+func TestWatchResources(t *testing.T) {
+	client := newFakeDynamicClient(
+		newUnstructured("eventing.kyma-project.io/v1alpha1", "Subscription", "default", "existing"),
+		newUnstructured("eventing.kyma-project.io/v1alpha1", "Subscription", "other", "ignored"),
+	)
+	cluster := &Cluster{DynamicClient: client}
+	server := newWatchServer(cluster)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events := openWatchStream(t, ctx, server.URL+"/watch?ns=default&kinds=subs")
+
+	expectWatchEvent(t, ctx, events, WatchAdded, "existing")
+	waitForWatch(t, client)
+
+	subs := client.Resource(subscription.GroupVersionResource())
+	created, err := subs.Namespace("default").Create(ctx,
+		newUnstructured("eventing.kyma-project.io/v1alpha1", "Subscription", "default", "created"), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectWatchEvent(t, ctx, events, WatchAdded, "created")
+
+	created.SetLabels(map[string]string{"team": "tunas"})
+	if _, err := subs.Namespace("default").Update(ctx, created, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectWatchEvent(t, ctx, events, WatchModified, "created")
+
+	if err := subs.Namespace("default").Delete(ctx, "existing", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectWatchEvent(t, ctx, events, WatchDeleted, "existing")
+}
+
+func TestWatchResourcesSharesCache(t *testing.T) {
+	client := newFakeDynamicClient(
+		newUnstructured("eventing.kyma-project.io/v1alpha1", "Subscription", "default", "existing"),
+		newUnstructured("eventing.kyma-project.io/v1alpha1", "Subscription", "other", "ignored"),
+	)
+	cluster := &Cluster{DynamicClient: client, Cache: informer.New(client, subscription.GroupVersionResource())}
+	defer cluster.Cache.Close()
+	server := newWatchServer(cluster)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	first := openWatchStream(t, ctx, server.URL+"/watch?ns=default&kinds=subs")
+	expectWatchEvent(t, ctx, first, WatchAdded, "existing")
+	second := openWatchStream(t, ctx, server.URL+"/watch?ns=default&kinds=subs")
+	expectWatchEvent(t, ctx, second, WatchAdded, "existing")
+	waitForWatch(t, client)
+
+	subs := client.Resource(subscription.GroupVersionResource())
+	if _, err := subs.Namespace("default").Create(ctx,
+		newUnstructured("eventing.kyma-project.io/v1alpha1", "Subscription", "default", "created"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectWatchEvent(t, ctx, first, WatchAdded, "created")
+	expectWatchEvent(t, ctx, second, WatchAdded, "created")
+
+	watches := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "watch" {
+			watches++
+		}
+	}
+	if watches != 1 {
+		t.Fatalf("expected the streams to share the informer cache, the API server was watched %d times", watches)
+	}
+}
+
+func TestWatchResourcesLimitsInformers(t *testing.T) {
+	// the cluster has no informer cache, so every stream starts its own informers
+	cluster := &Cluster{DynamicClient: newFakeDynamicClient(), informerWatches: maxInformerWatches}
+
+	rec := httptest.NewRecorder()
+	watchResources(rec, httptest.NewRequest(http.MethodGet, "/watch", nil).WithContext(
+		context.WithValue(context.Background(), clusterContextKey{}, cluster)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for too many watches, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if cluster.informerWatches != maxInformerWatches {
+		t.Fatalf("expected the rejected watch not to be counted, got %d watches", cluster.informerWatches)
+	}
+}
+
+func newWatchServer(cluster *Cluster) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		watchResources(w, r.WithContext(context.WithValue(r.Context(), clusterContextKey{}, cluster)))
+	}))
+}
+
+// openWatchStream opens a watch stream and returns its events, the channel is closed with the stream
+func openWatchStream(t *testing.T, ctx context.Context, url string) <-chan WatchEvent {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type: %s", resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan WatchEvent)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data := strings.TrimPrefix(scanner.Text(), "data: "); data != scanner.Text() {
+				var event WatchEvent
+				if err := json.Unmarshal([]byte(data), &event); err == nil {
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+		close(events)
+	}()
+	return events
+}
+
+func expectWatchEvent(t *testing.T, ctx context.Context, events <-chan WatchEvent, eventType, name string) {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatalf("stream closed, expected %s %s", eventType, name)
+		}
+		if event.Type != eventType || event.Kind != "subs" || event.Object.GetName() != name {
+			t.Fatalf("expected %s %s, got %s %s %s", eventType, name, event.Type, event.Kind, event.Object.GetName())
+		}
+	case <-ctx.Done():
+		t.Fatalf("timed out waiting for %s %s", eventType, name)
+	}
+}
+
+// waitForWatch waits until the informer watches the fake client, the fake watches miss the changes made before
+func waitForWatch(t *testing.T, client *dynamicfake.FakeDynamicClient) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		for _, action := range client.Actions() {
+			if action.GetVerb() == "watch" {
+				return
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for the informer to watch")
+}
+
+func TestWatchKindsParam(t *testing.T) {
+	if kinds, err := watchKindsParam(""); err != nil || strings.Join(kinds, ",") != "subs,funcs" {
+		t.Fatalf("expected all kinds by default, got %v, %v", kinds, err)
+	}
+	if kinds, err := watchKindsParam("funcs"); err != nil || strings.Join(kinds, ",") != "funcs" {
+		t.Fatalf("expected funcs, got %v, %v", kinds, err)
+	}
+	if _, err := watchKindsParam("subs,pods"); err == nil {
+		t.Fatal("expected an error for an unknown kind")
+	}
+}